@@ -0,0 +1,17 @@
+// Command yq is jq for YAML, XML and TOML documents. It is also xq and
+// tomlq when it runs under those names.
+package main
+
+import (
+	"os"
+
+	"github.com/bjhaid/yq/yq"
+)
+
+// version is the version reported by --version, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	os.Exit(yq.Main(os.Args, version))
+}
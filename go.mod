@@ -1,5 +1,11 @@
 module github.com/bjhaid/yq
 
-go 1.12
+go 1.18
 
-require gopkg.in/yaml.v3 v3.0.0-20191120175047-4206685974f2
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/itchyny/gojq v0.12.11
+	go.yaml.in/yaml/v3 v3.0.4
+)
+
+require github.com/itchyny/timefmt-go v0.1.5 // indirect
@@ -0,0 +1,301 @@
+package yq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// colorize reports whether the output is colored: with -C, or by default
+// when writing to a terminal and NO_COLOR is not set. -M always wins, as it
+// does with jq.
+func colorize(flags jqFlags) bool {
+	if flags.monochrome || flags.color {
+		return !flags.monochrome
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorSpec matches a color in JQ_COLORS and YQ_COLORS: SGR parameters
+// separated by semicolons.
+var colorSpec = regexp.MustCompile(`^[0-9;]{0,15}$`)
+
+// setColors sets the colors from the JQ_COLORS and YQ_COLORS environment
+// variables. JQ_COLORS holds the colors of null, false, true, numbers,
+// strings, arrays, objects and object keys, separated by colons, and
+// YQ_COLORS the same followed by the colors of YAML anchors, aliases and
+// tags, and of YAML comments. Like jq, yq warns about an invalid value and
+// ignores it.
+func setColors(stderr io.Writer) {
+	colors := []*string{&nullColor, &falseColor, &trueColor, &numberColor,
+		&stringColor, &arrayColor, &objectColor, &fieldColor}
+	set := func(name string, colors []*string) {
+		spec := os.Getenv(name)
+		if spec == "" {
+			return
+		}
+		fields := strings.Split(spec, ":")
+		if len(fields) > len(colors) {
+			fmt.Fprintf(stderr, "Failed to set $%s\n", name)
+			return
+		}
+		for _, field := range fields {
+			if !colorSpec.MatchString(field) {
+				fmt.Fprintf(stderr, "Failed to set $%s\n", name)
+				return
+			}
+		}
+		for i, field := range fields {
+			*colors[i] = field
+		}
+	}
+	set("JQ_COLORS", colors)
+	set("YQ_COLORS", append(colors, &anchorColor, &commentColor))
+}
+
+// highlightYAML colors data, YAML written by the encoder. It only knows the
+// YAML the encoder writes rather than YAML at large: flow collections fit
+// on a single line, and only block scalars and the plain scalars folded by
+// --width span several lines.
+func highlightYAML(data []byte) []byte {
+	h := yamlHighlighter{block: -1, plain: -1}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		h.line(line)
+	}
+	return h.buf.Bytes()
+}
+
+type yamlHighlighter struct {
+	buf bytes.Buffer
+	// block and plain are the indentation the lines following a block
+	// scalar header, or a plain scalar, are deeper than when they are part
+	// of the scalar, or -1.
+	block int
+	plain int
+	// parent is the column of the innermost key or sequence entry of the
+	// line.
+	parent int
+}
+
+func (h *yamlHighlighter) line(line string) {
+	text := strings.TrimSuffix(line, "\n")
+	content := strings.TrimLeft(text, " ")
+	indent := len(text) - len(content)
+	h.buf.WriteString(text[:indent])
+
+	switch {
+	case h.block >= 0 && (content == "" || indent > h.block):
+		h.write(stringColor, content)
+	case h.plain >= 0 && content != "" && content[0] != '#' && indent > h.plain:
+		h.scalar(content, stringColor)
+	case text == "---" || text == "...":
+		h.block, h.plain = -1, -1
+		h.buf.WriteString(content)
+	default:
+		h.block, h.plain = -1, -1
+		h.parent = indent
+		h.blockContent(text, indent)
+	}
+	h.buf.WriteString(line[len(text):])
+}
+
+// blockContent colors the block content of text from i.
+func (h *yamlHighlighter) blockContent(text string, i int) {
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ':
+			h.buf.WriteByte(c)
+			i++
+		case c == '#':
+			h.write(commentColor, text[i:])
+			return
+		case c == '-' && isBlankEnd(text, i+1):
+			h.parent = i
+			h.write(arrayColor, "-")
+			i++
+		case (c == '?' || c == ':') && isBlankEnd(text, i+1):
+			h.parent = i
+			h.write(objectColor, text[i:i+1])
+			i++
+		case c == '&' || c == '!' || c == '*':
+			i = h.property(text, i)
+		case c == '|' || c == '>':
+			h.block = h.parent
+			i = h.scalar(text[i:], stringColor) + i
+		case c == '[' || c == '{':
+			i = h.flow(text, i)
+		default:
+			end := scalarEnd(text, i, false)
+			if end < len(text) && text[end] == ':' && isBlankEnd(text, end+1) {
+				h.parent = i
+				h.write(fieldColor, text[i:end])
+				h.write(objectColor, ":")
+				i = end + 1
+				continue
+			}
+			if c != '"' && c != '\'' {
+				h.plain = h.parent
+			}
+			h.write(scalarColor(text[i:end]), text[i:end])
+			i = end
+		}
+	}
+}
+
+// flow colors the flow collection of text starting at i, returning where
+// it ends.
+func (h *yamlHighlighter) flow(text string, i int) int {
+	var containers []string
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == '[' || c == '{':
+			color := arrayColor
+			if c == '{' {
+				color = objectColor
+			}
+			containers = append(containers, color)
+			h.write(color, text[i:i+1])
+			i++
+		case c == ']' || c == '}':
+			h.write(containers[len(containers)-1], text[i:i+1])
+			containers = containers[:len(containers)-1]
+			i++
+			if len(containers) == 0 {
+				return i
+			}
+		case c == ',' || c == ':':
+			h.write(containers[len(containers)-1], text[i:i+1])
+			i++
+		case c == ' ':
+			h.buf.WriteByte(c)
+			i++
+		case c == '&' || c == '!' || c == '*':
+			i = h.property(text, i)
+		default:
+			end := scalarEnd(text, i, true)
+			color := scalarColor(text[i:end])
+			if end < len(text) && text[end] == ':' {
+				color = fieldColor
+			}
+			h.write(color, text[i:end])
+			i = end
+		}
+	}
+	return i
+}
+
+// property colors the anchor, alias or tag of text starting at i,
+// returning where it ends.
+func (h *yamlHighlighter) property(text string, i int) int {
+	end := i + 1
+	for end < len(text) && !strings.ContainsRune(" ,[]{}", rune(text[end])) {
+		end++
+	}
+	h.write(anchorColor, text[i:end])
+	return end
+}
+
+// scalar colors the scalar at the start of text and the comment that may
+// follow it, returning where the scalar ends.
+func (h *yamlHighlighter) scalar(text, color string) int {
+	end := strings.Index(text, " #")
+	if end < 0 {
+		end = len(text)
+	}
+	h.write(color, text[:end])
+	if end < len(text) {
+		h.buf.WriteByte(' ')
+		h.write(commentColor, text[end+1:])
+	}
+	return len(text)
+}
+
+func (h *yamlHighlighter) write(color, s string) {
+	if s == "" {
+		return
+	}
+	h.buf.WriteString("\x1b[" + color + "m")
+	h.buf.WriteString(s)
+	h.buf.WriteString("\x1b[0m")
+}
+
+// isBlankEnd reports whether text ends at i or has a space there.
+func isBlankEnd(text string, i int) bool {
+	return i >= len(text) || text[i] == ' '
+}
+
+// scalarEnd returns where the scalar of text starting at i ends: after the
+// closing quote of a quoted scalar, or for a plain scalar before a value
+// indicator, a comment or, in a flow collection, a flow indicator.
+func scalarEnd(text string, i int, flow bool) int {
+	switch text[i] {
+	case '"':
+		for j := i + 1; j < len(text); j++ {
+			if text[j] == '\\' {
+				j++
+			} else if text[j] == '"' {
+				return j + 1
+			}
+		}
+		return len(text)
+	case '\'':
+		for j := i + 1; j < len(text); j++ {
+			if text[j] == '\'' {
+				if j+1 < len(text) && text[j+1] == '\'' {
+					j++
+					continue
+				}
+				return j + 1
+			}
+		}
+		return len(text)
+	}
+
+	for j := i; j < len(text); j++ {
+		switch c := text[j]; {
+		case c == ':' && (isBlankEnd(text, j+1) ||
+			flow && strings.ContainsRune(",[]{}", rune(text[j+1]))):
+			return j
+		case c == '#' && j > i && text[j-1] == ' ':
+			return j - 1
+		case flow && strings.ContainsRune(",[]{}", rune(c)):
+			return j
+		}
+	}
+	return len(text)
+}
+
+// scalarColor returns the color of a scalar from the type it resolves to.
+func scalarColor(s string) string {
+	if s[0] == '"' || s[0] == '\'' {
+		return stringColor
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &node); err != nil ||
+		len(node.Content) == 0 {
+		return stringColor
+	}
+	switch node.Content[0].ShortTag() {
+	case "!!null":
+		return nullColor
+	case "!!bool":
+		if strings.EqualFold(node.Content[0].Value, "true") {
+			return trueColor
+		}
+		return falseColor
+	case "!!int", "!!float":
+		return numberColor
+	}
+	return stringColor
+}
@@ -0,0 +1,75 @@
+package yq
+
+import (
+	"testing"
+)
+
+func TestHighlightYAML(t *testing.T) {
+	c := func(color, s string) string {
+		return "\x1b[" + color + "m" + s + "\x1b[0m"
+	}
+	key := func(s string) string {
+		return c(fieldColor, s) + c(objectColor, ":")
+	}
+	item := c(arrayColor, "-")
+
+	type testCase struct {
+		testDescription string
+		yaml            string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Colors keys and scalars by type",
+			"a: 1\nb: true\nc: false\nd: null\ne: text\nf: \"1\"\n",
+			key("a") + " " + c(numberColor, "1") + "\n" +
+				key("b") + " " + c(trueColor, "true") + "\n" +
+				key("c") + " " + c(falseColor, "false") + "\n" +
+				key("d") + " " + c(nullColor, "null") + "\n" +
+				key("e") + " " + c(stringColor, "text") + "\n" +
+				key("f") + " " + c(stringColor, `"1"`) + "\n",
+		},
+		{
+			"Colors sequences, comments and anchors",
+			"# head\nl: &x\n  - a # line\n  - *x\n",
+			c(commentColor, "# head") + "\n" +
+				key("l") + " " + c(anchorColor, "&x") + "\n" +
+				"  " + item + " " + c(stringColor, "a") + " " +
+				c(commentColor, "# line") + "\n" +
+				"  " + item + " " + c(anchorColor, "*x") + "\n",
+		},
+		{
+			"Colors flow collections",
+			"f: [1, {k: v}]\n",
+			key("f") + " " + c(arrayColor, "[") + c(numberColor, "1") +
+				c(arrayColor, ",") + " " + c(objectColor, "{") +
+				c(fieldColor, "k") + c(objectColor, ":") + " " +
+				c(stringColor, "v") + c(objectColor, "}") + c(arrayColor, "]") +
+				"\n",
+		},
+		{
+			"Colors block scalars and folded plain scalars",
+			"a: |\n  text: 1\n\n  - b\nb: one\n  two\nc: 3\n",
+			key("a") + " " + c(stringColor, "|") + "\n" +
+				"  " + c(stringColor, "text: 1") + "\n\n" +
+				"  " + c(stringColor, "- b") + "\n" +
+				key("b") + " " + c(stringColor, "one") + "\n" +
+				"  " + c(stringColor, "two") + "\n" +
+				key("c") + " " + c(numberColor, "3") + "\n",
+		},
+		{
+			"Leaves document markers",
+			"a: 1\n---\nurl: http://x\n",
+			key("a") + " " + c(numberColor, "1") + "\n---\n" +
+				key("url") + " " + c(stringColor, "http://x") + "\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			actual := string(highlightYAML([]byte(tCase.yaml)))
+			if tCase.expected != actual {
+				t.Errorf("Expected '%q' got '%q'", tCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package yq
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// sources maps the nodes of a result of the filter to the input nodes they
+// were moved from, where that differs from the path they are found at.
+type sources map[*yaml.Node]*yaml.Node
+
+func (s sources) add(node, source *yaml.Node) {
+	if s != nil {
+		s[node] = source
+	}
+}
+
+// copyComments copies the comments of orig onto node, then recurses into the
+// children both share by path, that is the values under the same mapping
+// key or at the same sequence index. Nodes found in moved, and sequence
+// items that are all found in orig, as filters such as select, reverse or
+// sort leave them, take the comments of the node they stem from instead.
+// Nodes whose kind changed are considered replaced by the filter and keep
+// no comments.
+func copyComments(node, orig *yaml.Node, moved sources) {
+	if source, ok := moved[node]; ok {
+		orig = source
+	}
+	if orig == nil {
+		return
+	}
+	content := orig
+	if orig.Kind == yaml.AliasNode {
+		content = orig.Alias
+	}
+	if content == nil || node.Kind != content.Kind {
+		return
+	}
+
+	node.HeadComment = orig.HeadComment
+	node.LineComment = orig.LineComment
+	node.FootComment = orig.FootComment
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for i := 0; i < len(node.Content) && i < len(content.Content); i++ {
+			copyComments(node.Content[i], content.Content[i], moved)
+		}
+	case yaml.SequenceNode:
+		for i, item := range itemSources(node, content) {
+			copyComments(node.Content[i], item, moved)
+		}
+	case yaml.MappingNode:
+		keys := map[string]int{}
+		for i := 0; i < len(content.Content); i += 2 {
+			keys[content.Content[i].Value] = i
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			j, ok := keys[node.Content[i].Value]
+			if !ok {
+				continue
+			}
+			copyComments(node.Content[i], content.Content[j], moved)
+			copyComments(node.Content[i+1], content.Content[j+1], moved)
+		}
+	}
+}
+
+// itemSources returns the items of orig the items of node stem from, or nil
+// for the items that have none. When each item of node holds the value of
+// an item of orig, the items are matched by value, preferring the item at
+// the same index, otherwise by index.
+func itemSources(node, orig *yaml.Node) []*yaml.Node {
+	items := make([]*yaml.Node, len(node.Content))
+	keys := map[*yaml.Node]string{}
+	byValue := map[string][]*yaml.Node{}
+	for _, item := range orig.Content {
+		if key := valueKey(item, keys); key != "" {
+			byValue[key] = append(byValue[key], item)
+		}
+	}
+
+	used := map[*yaml.Node]bool{}
+	for i, item := range node.Content {
+		if i >= len(orig.Content) {
+			break
+		}
+		if key := valueKey(item, keys); key != "" && key == valueKey(orig.Content[i], keys) {
+			items[i] = orig.Content[i]
+			used[items[i]] = true
+		}
+	}
+	for i, item := range node.Content {
+		if items[i] != nil {
+			continue
+		}
+		same := byValue[valueKey(item, keys)]
+		if len(same) == 0 {
+			// The items were modified in place, or replaced.
+			for j := range items {
+				items[j] = nil
+				if j < len(orig.Content) {
+					items[j] = orig.Content[j]
+				}
+			}
+			return items
+		}
+		items[i] = same[0]
+		for _, s := range same {
+			if !used[s] {
+				items[i] = s
+				break
+			}
+		}
+		used[items[i]] = true
+	}
+	return items
+}
+
+// movedValues returns the collections of node, a result of jq, that hold
+// the value of a collection of orig other than the one at their path. jq
+// does not tell where values come from, so a collection the filter left
+// untouched is taken to stem from the first collection of the input
+// holding the same value.
+func movedValues(node, orig *yaml.Node) sources {
+	if orig == nil {
+		return nil
+	}
+	keys := map[*yaml.Node]string{}
+	byValue := map[string]*yaml.Node{}
+	var index func(*yaml.Node)
+	index = func(n *yaml.Node) {
+		n = resolve(n)
+		if n == nil || len(n.Content) == 0 {
+			return
+		}
+		if key := valueKey(n, keys); key != "" && byValue[key] == nil {
+			byValue[key] = n
+		}
+		for _, child := range n.Content {
+			index(child)
+		}
+	}
+	index(orig)
+
+	moved := sources{}
+	var walk func(n, o *yaml.Node)
+	walk = func(n, o *yaml.Node) {
+		o = resolve(o)
+		if n.Kind != yaml.MappingNode && n.Kind != yaml.SequenceNode {
+			return
+		}
+		key := valueKey(n, keys)
+		if key == "" || o != nil && key == valueKey(o, keys) {
+			return
+		}
+		if source := byValue[key]; source != nil {
+			moved.add(n, source)
+			return
+		}
+		switch {
+		case o == nil || o.Kind != n.Kind:
+			for _, child := range n.Content {
+				walk(child, nil)
+			}
+		case n.Kind == yaml.SequenceNode:
+			for i, child := range n.Content {
+				var item *yaml.Node
+				if i < len(o.Content) {
+					item = o.Content[i]
+				}
+				walk(child, item)
+			}
+		default:
+			values := map[string]*yaml.Node{}
+			for j := 0; j < len(o.Content); j += 2 {
+				values[o.Content[j].Value] = o.Content[j+1]
+			}
+			for i := 1; i < len(n.Content); i += 2 {
+				walk(n.Content[i], values[n.Content[i-1].Value])
+			}
+		}
+	}
+	walk(resolve(node), orig)
+	return moved
+}
+
+// valueKey returns a text that nodes holding the same JSON value share,
+// whatever the literals, tags and key order they are written with, or an
+// empty text for nodes that have no JSON value. keys records the texts of
+// the nodes already seen.
+func valueKey(node *yaml.Node, keys map[*yaml.Node]string) string {
+	node = resolve(node)
+	if node == nil {
+		return "null"
+	}
+	if key, ok := keys[node]; ok {
+		return key
+	}
+
+	var key string
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(node)
+		if err != nil {
+			break
+		}
+		entries := make([]string, len(pairs))
+		for i, p := range pairs {
+			entries[i] = strconv.Quote(p.key) + ":" + valueKey(p.value, keys)
+		}
+		sort.Strings(entries)
+		key = "{" + strings.Join(entries, ",") + "}"
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			items[i] = valueKey(item, keys)
+		}
+		key = "[" + strings.Join(items, ",") + "]"
+	case yaml.ScalarNode:
+		if n, ok := numberValue(node); ok {
+			key = n
+		} else if node.ShortTag() == "!!null" {
+			key = "null"
+		} else {
+			key = node.ShortTag() + strconv.Quote(node.Value)
+		}
+	}
+	keys[node] = key
+	return key
+}
@@ -0,0 +1,65 @@
+package yq
+
+import (
+	"encoding/csv"
+	"io"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// csvDecoder decodes a CSV document into an array holding an object for
+// each record, keyed by the fields of the header, the first record. Every
+// value is a string.
+type csvDecoder struct {
+	r    *csv.Reader
+	done bool
+}
+
+func newCSVDecoder(r io.Reader) *csvDecoder {
+	return &csvDecoder{r: csv.NewReader(r)}
+}
+
+// Decode decodes the document into v, a *yaml.Node. A CSV input holds a
+// single document.
+func (d *csvDecoder) Decode(v interface{}) error {
+	if d.done {
+		return io.EOF
+	}
+	d.done = true
+
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	var header []*yaml.Node
+	for {
+		record, err := d.r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err, ok := err.(*csv.ParseError); ok {
+			return &DecodeError{Format: "csv", Line: err.Line,
+				Column: err.Column, Message: err.Err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := d.r.FieldPos(0)
+		if header == nil {
+			for i, field := range record {
+				key := stringNode(field)
+				key.Line, key.Column = line, i+1
+				header = append(header, key)
+			}
+			continue
+		}
+		row := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line,
+			Column: 1}
+		for i, field := range record {
+			row.Content = append(row.Content, header[i], stringNode(field))
+		}
+		seq.Content = append(seq.Content, row)
+	}
+
+	*v.(*yaml.Node) = yaml.Node{Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{seq}}
+	return nil
+}
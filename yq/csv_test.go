@@ -0,0 +1,62 @@
+package yq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVDecoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		csv             string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Reads records as objects keyed by the header",
+			"name,age\nann,3\n\"bob, jr\",4\n",
+			`[{"name":"ann","age":"3"},{"name":"bob, jr","age":"4"}]`,
+			false,
+		},
+		{
+			"Reads an empty input as an empty array",
+			"",
+			`[]`,
+			false,
+		},
+		{
+			"Errors on records with missing fields",
+			"name,age\nann\n",
+			"",
+			true,
+		},
+		{
+			"Errors on duplicate fields in the header",
+			"name,name\nann,bob\n",
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			ct := jqPipe{documentOptions: documentOptions{inputFormat: "csv"}}
+			err := ct.toJSON(strings.NewReader(tCase.csv), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toJSON to return an error and it did not")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package yq
+
+import (
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// documentOptions are the options that shape the documents the filter
+// sees and the documents written from its results. jqPipe, for the jq
+// binary, and the engine both go through prepare and finish, so that they
+// treat documents the same way.
+type documentOptions struct {
+	// inputFormat is the format of every input, or empty to tell it from
+	// the name or the content of each.
+	inputFormat    string
+	format         outputFormat
+	properties     nodeProperties
+	mergeKeys      string
+	keyPolicy      string
+	restoreIntKeys bool
+	scalars        scalarPolicy
+	emptyDocs      string
+	withFilename   bool
+}
+
+// prepare returns doc, the nth document of file, as the filter sees it, or
+// nil if it is skipped. The document returned is the original of the
+// results of the filter, which scalars.apply turns into the one converted
+// to JSON.
+func (o *documentOptions) prepare(doc *yaml.Node, file string, n int) (*yaml.Node, error) {
+	if isNull(doc) && o.emptyDocs != "null" {
+		return nil, nil
+	}
+	doc, err := mergeKeys(doc, o.mergeKeys)
+	if err == nil {
+		doc, err = keyPolicy(doc, o.keyPolicy)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.properties.enabled() {
+		doc = o.properties.wrap(doc)
+	}
+	if o.withFilename {
+		doc = withFilename(doc, file, n)
+	}
+	return doc, nil
+}
+
+// finish returns the document written for doc, a result of the filter
+// converted back into YAML nodes, given orig, the prepared document it
+// stems from, or nil if it is not known, and moved, the nodes of doc moved
+// from another path of orig.
+func (o *documentOptions) finish(doc, orig *yaml.Node, moved sources) *yaml.Node {
+	copyComments(doc, orig, moved)
+	if o.format.isYAML() || o.format.syntax == "toml" {
+		keepLiterals(doc, orig, moved, o.scalars, o.format)
+	}
+	if o.withFilename {
+		doc = withoutFilename(doc)
+	}
+	if o.properties.enabled() && o.format.isYAML() {
+		doc = o.properties.unwrap(doc)
+	}
+	if o.restoreIntKeys && o.format.isYAML() {
+		restoreIntKeys(doc)
+	}
+	return doc
+}
@@ -0,0 +1,286 @@
+package yq
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// outputFormat holds the options formatting the documents transcoded from
+// jq's output: YAML with -y, XML with -x or TOML with -t, the syntax being
+// empty, xml or toml. Files edited in place are written in the syntax they
+// were read in, which may also be yaml or json. The zero value is YAML in
+// the format of the YAML encoder.
+type outputFormat struct {
+	syntax          string
+	xmlRoot         string
+	indent          int
+	width           int
+	flowLevel       int
+	explicitStart   bool
+	explicitEnd     bool
+	indentlessLists bool
+	color           bool
+}
+
+// isYAML reports whether the documents are written in YAML.
+func (f outputFormat) isYAML() bool {
+	return f.syntax == "" || f.syntax == "yaml"
+}
+
+// encoder writes documents transcoded from jq's output.
+type encoder interface {
+	Encode(doc *yaml.Node) error
+}
+
+func newEncoder(w io.Writer, format outputFormat) encoder {
+	switch format.syntax {
+	case "xml":
+		return &xmlEncoder{w: w, format: format}
+	case "toml":
+		return &tomlEncoder{w: w}
+	case "json":
+		return &jsonEncoder{w: w, format: format}
+	}
+	return newYAMLEncoder(w, format)
+}
+
+// yamlEncoder writes YAML documents in an outputFormat. Every document is
+// encoded on its own, so that yq writes the markers between them.
+type yamlEncoder struct {
+	w       io.Writer
+	format  outputFormat
+	buf     bytes.Buffer
+	written bool
+}
+
+func newYAMLEncoder(w io.Writer, format outputFormat) *yamlEncoder {
+	return &yamlEncoder{w: w, format: format}
+}
+
+// Encode writes doc, a DocumentNode.
+func (e *yamlEncoder) Encode(doc *yaml.Node) error {
+	if err := checkAliases(doc); err != nil {
+		return err
+	}
+	if e.format.flowLevel > 0 {
+		setFlowStyle(doc, e.format.flowLevel)
+	}
+	var wrapped *wrappedStrings
+	if e.format.width > 0 {
+		wrapped = wrapStrings(doc)
+		defer wrapped.restore()
+	}
+
+	e.buf.Reset()
+	if e.written || e.format.explicitStart {
+		e.buf.WriteString("---\n")
+	}
+
+	enc := yaml.NewEncoder(&e.buf)
+	enc.SetIndent(2)
+	if e.format.indent > 0 {
+		enc.SetIndent(e.format.indent)
+	}
+	if e.format.indentlessLists {
+		enc.CompactSeqIndent()
+	}
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if e.format.explicitEnd {
+		e.buf.WriteString("...\n")
+	}
+
+	data := e.buf.Bytes()
+	if wrapped != nil {
+		data = wrapped.wrap(data, e.format)
+	}
+	if e.format.color {
+		data = highlightYAML(data)
+	}
+	e.written = true
+	_, err := e.w.Write(data)
+	return err
+}
+
+// setFlowStyle sets the flow style on the collections of node nested level
+// deep or deeper, the root of the document being at level 1.
+func setFlowStyle(node *yaml.Node, level int) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			setFlowStyle(child, level)
+		}
+	case yaml.SequenceNode, yaml.MappingNode:
+		if level <= 1 {
+			node.Style |= yaml.FlowStyle
+		}
+		for _, child := range node.Content {
+			setFlowStyle(child, level-1)
+		}
+	}
+}
+
+// wrappedStrings holds the strings of a document that can be wrapped,
+// replaced by placeholders while the document is encoded. The encoder
+// never breaks lines itself, so yq folds the strings into multi-line plain
+// scalars where it finds the placeholders.
+type wrappedStrings struct {
+	nodes       []*yaml.Node
+	values      []string
+	placeholder *regexp.Regexp
+}
+
+func wrapStrings(doc *yaml.Node) *wrappedStrings {
+	w := &wrappedStrings{}
+	var values []string
+	w.collect(doc, false, &values)
+
+	// The placeholders must not appear anywhere else in the document.
+	prefix := "yqwrap"
+	for n := 0; containsAny(values, prefix); n++ {
+		prefix = "yqwrap" + strconv.Itoa(n) + "x"
+	}
+	w.placeholder = regexp.MustCompile(prefix + `(\d+)`)
+	for i, node := range w.nodes {
+		w.values = append(w.values, node.Value)
+		node.Value = prefix + strconv.Itoa(i)
+	}
+	return w
+}
+
+// collect gathers the string values that the encoder writes as plain
+// scalars and that hold spaces to break lines at, and every scalar in
+// values.
+func (w *wrappedStrings) collect(node *yaml.Node, key bool, values *[]string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*values = append(*values, node.Value)
+		if !key && node.Style == 0 && node.ShortTag() == "!!str" &&
+			strings.Contains(node.Value, " ") && isPlain(node.Value) {
+			w.nodes = append(w.nodes, node)
+		}
+	case yaml.MappingNode:
+		for i, child := range node.Content {
+			w.collect(child, i%2 == 0, values)
+		}
+	default:
+		for _, child := range node.Content {
+			w.collect(child, false, values)
+		}
+	}
+}
+
+func (w *wrappedStrings) restore() {
+	for i, node := range w.nodes {
+		node.Value = w.values[i]
+	}
+}
+
+// isPlain reports whether s is written as a plain scalar.
+func isPlain(s string) bool {
+	if strings.Contains(s, "\n") {
+		return false
+	}
+	data, err := yaml.Marshal(s)
+	return err == nil && string(data) == s+"\n"
+}
+
+func containsAny(values []string, s string) bool {
+	for _, v := range values {
+		if strings.Contains(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap replaces the placeholders in data, the encoded document, with their
+// strings folded to the width of format. Strings are only folded when they
+// are the last thing on a line of block content.
+func (w *wrappedStrings) wrap(data []byte, format outputFormat) []byte {
+	indent := format.indent
+	if indent < 2 {
+		indent = 2
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		loc := w.placeholder.FindStringSubmatchIndex(line)
+		for loc != nil {
+			n, _ := strconv.Atoi(line[loc[2]:loc[3]])
+			before, after := line[:loc[0]], line[loc[1]:]
+			value := w.values[n]
+
+			content := strings.TrimLeft(before, " ")
+			for strings.HasPrefix(content, "- ") {
+				content = strings.TrimLeft(content[2:], " ")
+			}
+			column := len(before) - len(content)
+			rest := strings.TrimSuffix(after, "\n")
+			if rest == "" || strings.HasPrefix(rest, " #") {
+				switch {
+				case content == "" && column > 0:
+					value = foldPlain(value, utf8.RuneCountInString(before),
+						column, format.width)
+				case strings.HasSuffix(content, ": "):
+					value = foldPlain(value, utf8.RuneCountInString(before),
+						column+indent, format.width)
+				}
+			}
+			line = before + value + after
+			loc = w.placeholder.FindStringSubmatchIndex(line[len(before)+len(value):])
+			if loc != nil {
+				for j := range loc {
+					loc[j] += len(before) + len(value)
+				}
+			}
+		}
+		lines[i] = line
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+// foldPlain breaks s, a plain scalar starting at column, into lines no
+// longer than width where it can, continuing on lines indented by indent.
+// Lines are only broken at single spaces, which YAML folds back into a
+// space, and never before a character that would start a YAML indicator.
+func foldPlain(s string, column, indent, width int) string {
+	var chunks []string
+	for i, part := range strings.Split(s, " ") {
+		last := len(chunks) - 1
+		if i > 0 && (part == "" || chunks[last] == "" ||
+			strings.HasSuffix(chunks[last], " ")) {
+			chunks[last] += " " + part
+			continue
+		}
+		chunks = append(chunks, part)
+	}
+
+	var b strings.Builder
+	for i, chunk := range chunks {
+		length := utf8.RuneCountInString(chunk)
+		if i > 0 {
+			if column+1+length > width && !strings.ContainsAny(chunk[:1],
+				"-?:,[]{}#&*!|>'\"%@`") {
+				b.WriteString("\n" + strings.Repeat(" ", indent))
+				column = indent
+			} else {
+				b.WriteByte(' ')
+				column++
+			}
+		}
+		b.WriteString(chunk)
+		column += length
+	}
+	return b.String()
+}
@@ -0,0 +1,595 @@
+package yq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/itchyny/gojq"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// engine evaluates the jq filter in process with gojq, honoring the same jq
+// flags yq forwards to the jq binary when --jq-binary is used.
+type engine struct {
+	code        *gojq.Code
+	definitions []jqVariable
+	positional  []jqPositional
+	variables   []interface{}
+	inputs      *documentIter
+	failed      bool
+	status      int
+	events      []interface{}
+	stderr      io.Writer
+	ctx         context.Context
+	stopOnError bool
+
+	returnYAML bool
+	documentOptions
+	jqFlags
+}
+
+func newEngine(yq *yq, filter string) (*engine, error) {
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return nil, &CompileError{Err: err}
+	}
+
+	e := &engine{
+		inputs:          &documentIter{},
+		stderr:          os.Stderr,
+		returnYAML:      yq.returnYAML,
+		documentOptions: yq.documentOptions,
+		jqFlags:         yq.jqFlags,
+	}
+
+	names := []string{}
+	for _, v := range yq.variables {
+		names = append(names, "$"+v.name)
+	}
+	e.definitions = yq.variables
+	e.positional = yq.positional
+	names = append(names, "$ARGS")
+
+	libraryPaths := yq.libraryPaths
+	if len(libraryPaths) == 0 {
+		libraryPaths = []string{"~/.jq"}
+	}
+	e.code, err = gojq.Compile(query,
+		gojq.WithModuleLoader(gojq.NewModuleLoader(libraryPaths)),
+		gojq.WithEnvironLoader(os.Environ),
+		gojq.WithVariables(names),
+		gojq.WithInputIter(e.inputs),
+		gojq.WithFunction("input_filename", 0, 0,
+			func(interface{}, []interface{}) interface{} {
+				if path := e.inputs.path; path != "" {
+					return path
+				}
+				return nil
+			}),
+	)
+	if err != nil {
+		return nil, &CompileError{Err: err}
+	}
+	return e, nil
+}
+
+// compileError returns err, a CompileError, as the error jq exits with.
+func compileError(err error) error {
+	return &exitError{status: 3,
+		message: fmt.Sprintf("%v\njq: 1 compile error", err)}
+}
+
+// bindVariables computes the values of the variables defined on the command
+// line, once, before the filter first runs.
+func (e *engine) bindVariables() error {
+	if e.variables != nil {
+		return nil
+	}
+
+	variables := []interface{}{}
+	named := map[string]interface{}{}
+	for _, v := range e.definitions {
+		value, err := v.bind()
+		if err != nil {
+			return err
+		}
+		variables = append(variables, value)
+		named[v.name] = value
+	}
+	positional := []interface{}{}
+	for _, p := range e.positional {
+		value, err := p.bind()
+		if err != nil {
+			return err
+		}
+		positional = append(positional, value)
+	}
+	e.variables = append(variables, map[string]interface{}{
+		"positional": positional,
+		"named":      named,
+	})
+	return nil
+}
+
+// bind returns the value of $ARGS.positional the parameter stands for.
+func (p jqPositional) bind() (interface{}, error) {
+	if p.flag != "--jsonargs" {
+		return p.value, nil
+	}
+	values, err := decodeJSONTexts(strings.NewReader(p.value))
+	if err != nil || len(values) != 1 {
+		return nil, fmt.Errorf("Invalid JSON text passed to --jsonargs")
+	}
+	return values[0], nil
+}
+
+// bind returns the value the variable is bound to, as jq would compute it
+// from the command line flag defining it.
+func (v jqVariable) bind() (interface{}, error) {
+	switch v.flag {
+	case "--argjson":
+		values, err := decodeJSONTexts(strings.NewReader(v.value))
+		if err != nil || len(values) != 1 {
+			return nil, fmt.Errorf("Invalid JSON text passed to --argjson %s",
+				v.name)
+		}
+		return values[0], nil
+	case "--slurpfile", "--argfile":
+		file, err := os.Open(v.value)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		values, err := decodeJSONTexts(file)
+		if err != nil {
+			return nil, fmt.Errorf("Bad JSON in %s %s %s: %v", v.flag, v.name,
+				v.value, err)
+		}
+		if v.flag == "--argfile" && len(values) == 1 {
+			return values[0], nil
+		}
+		return values, nil
+	case "--rawfile":
+		data, err := ioutil.ReadFile(v.value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return v.value, nil
+}
+
+// decodeJSONTexts decodes the whitespace separated JSON texts read from r.
+func decodeJSONTexts(r io.Reader) ([]interface{}, error) {
+	values := []interface{}{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			if err == io.EOF {
+				return values, nil
+			}
+			return nil, err
+		}
+		values = append(values, normalizeNumbers(value))
+	}
+}
+
+// normalizeNumbers replaces the json.Number values in v with the numeric
+// types gojq operates on.
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	}
+	return v
+}
+
+// run applies the filter to the documents of files, or of stdin if there are
+// no files, and writes the results to w. It returns an exitError when jq
+// would exit with a non-zero status, after reporting runtime errors on
+// stderr.
+func (e *engine) run(files []string, stdin io.Reader, w io.Writer) error {
+	inputs := make([]Input, len(files))
+	for i, file := range files {
+		inputs[i] = Input{Name: file}
+	}
+	if len(files) == 0 && stdin != nil {
+		inputs = []Input{{Reader: stdin}}
+	}
+	return e.runInputs(context.Background(), inputs, w)
+}
+
+// runInputs is run for any inputs, until ctx is done.
+func (e *engine) runInputs(ctx context.Context, inputs []Input, w io.Writer) error {
+	if err := e.bindVariables(); err != nil {
+		return err
+	}
+
+	e.ctx = ctx
+	*e.inputs = documentIter{inputs: inputs,
+		documentOptions: e.documentOptions, origins: origins{}}
+	defer e.inputs.close()
+	e.failed, e.status = false, 0
+
+	out := &output{w: w, engine: e}
+	if e.returnYAML {
+		out.enc = newEncoder(w, e.format)
+	} else {
+		out.printer = newJSONPrinter(e.jqFlags, colorize(e.jqFlags))
+	}
+
+	if err := e.evalInputs(out); err != nil {
+		return err
+	}
+	if e.status != 0 {
+		return &exitError{status: e.status}
+	}
+	return nil
+}
+
+func (e *engine) evalInputs(out *output) error {
+	if e.nullAsSingleInputValue {
+		return e.eval(nil, nil, out)
+	}
+
+	if e.slurp {
+		values := []interface{}{}
+		seq := &yaml.Node{Kind: yaml.SequenceNode}
+		doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{seq}}
+		for {
+			v, ok := e.next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				return err
+			}
+			values = append(values, v)
+			if !e.stream {
+				seq.Content = append(seq.Content, resolve(e.inputs.doc))
+			}
+		}
+		if e.rawString {
+			var raw strings.Builder
+			for _, v := range values {
+				raw.WriteString(v.(string))
+			}
+			return e.eval(raw.String(), nil, out)
+		}
+		return e.eval(values, doc, out)
+	}
+
+	for {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+		v, ok := e.next()
+		if !ok {
+			return nil
+		}
+		if err, ok := v.(error); ok {
+			return err
+		}
+		var orig *yaml.Node
+		if !e.stream {
+			orig = e.inputs.doc
+		}
+		if err := e.eval(v, orig, out); err != nil {
+			return err
+		}
+		e.inputs.origins = origins{}
+	}
+}
+
+// next returns the next input value. With -R, jq reads the JSON texts yq
+// would have piped into it as raw lines, so the value is the document as a
+// line of compact JSON, and --stream has no effect.
+func (e *engine) next() (interface{}, bool) {
+	if e.stream && !e.rawString {
+		return e.nextEvent()
+	}
+
+	v, ok := e.inputs.Next()
+	if !ok || !e.rawString {
+		return v, ok
+	}
+	if _, isErr := v.(error); isErr {
+		return v, ok
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, e.scalars.apply(e.inputs.doc)); err != nil {
+		return err, true
+	}
+	buf.WriteByte('\n')
+	if !e.slurp {
+		return strings.TrimSuffix(buf.String(), "\n"), true
+	}
+	return buf.String(), true
+}
+
+// nextEvent returns the next event of the streaming form of the inputs, as
+// produced by jq's --stream.
+func (e *engine) nextEvent() (interface{}, bool) {
+	for len(e.events) == 0 {
+		v, ok := e.inputs.Next()
+		if !ok {
+			return nil, false
+		}
+		if _, isErr := v.(error); isErr {
+			return v, true
+		}
+		events, err := streamEvents(nil, []interface{}{}, e.scalars.apply(e.inputs.doc))
+		if err != nil {
+			return err, true
+		}
+		e.events = events
+	}
+
+	event := e.events[0]
+	e.events = e.events[1:]
+	return event, true
+}
+
+// streamEvents appends the streaming form of node, found at path, to events:
+// a [path, leaf] event for every scalar and empty collection, and a closing
+// [path] event after the last child of every collection.
+func streamEvents(events []interface{}, path []interface{}, node *yaml.Node) ([]interface{}, error) {
+	node = resolve(node)
+	at := func(key interface{}) []interface{} {
+		return append(append([]interface{}{}, path...), key)
+	}
+
+	var last interface{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(node)
+		if err != nil {
+			return nil, err
+		}
+		if len(pairs) == 0 {
+			return append(events, []interface{}{path, map[string]interface{}{}}), nil
+		}
+		for _, p := range pairs {
+			if events, err = streamEvents(events, at(p.key), p.value); err != nil {
+				return nil, err
+			}
+			last = p.key
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			return append(events, []interface{}{path, []interface{}{}}), nil
+		}
+		for i, item := range node.Content {
+			var err error
+			if events, err = streamEvents(events, at(i), item); err != nil {
+				return nil, err
+			}
+			last = i
+		}
+	default:
+		v, err := scalarValue(node)
+		if err != nil {
+			return nil, err
+		}
+		return append(events, []interface{}{path, v}), nil
+	}
+	return append(events, []interface{}{at(last)}), nil
+}
+
+// eval runs the filter on a single input and writes every result, reporting
+// runtime errors on stderr the way jq does before moving on to the next
+// input, or returns the error with stopOnError. Like jq, it sets the exit
+// status from the last input only: 5 after a runtime error and, with -e, 1
+// when the last result is false or null and 4 when there is no result at
+// all.
+func (e *engine) eval(v interface{}, orig *yaml.Node, out *output) error {
+	e.status = 0
+	if e.exitStatusCodeBasedOnOutput {
+		e.status = 4
+	}
+
+	iter := e.code.RunWithContext(e.ctx, v, e.variables...)
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := result.(error); ok {
+			if err := e.ctx.Err(); err != nil {
+				return err
+			}
+			err := &RuntimeError{File: e.inputs.name, Err: err}
+			if e.stopOnError {
+				return err
+			}
+			fmt.Fprintln(e.stderr, err)
+			e.failed, e.status = true, 5
+			return nil
+		}
+		if err := out.write(result, orig); err != nil {
+			return err
+		}
+		if e.exitStatusCodeBasedOnOutput {
+			e.status = 0
+			if result == nil || result == false {
+				e.status = 1
+			}
+		}
+	}
+}
+
+// output writes the results of the filter either as YAML or as JSON texts.
+type output struct {
+	w       io.Writer
+	enc     encoder
+	printer *jsonPrinter
+	buf     bytes.Buffer
+	*engine
+}
+
+func (o *output) write(v interface{}, orig *yaml.Node) error {
+	if o.enc != nil {
+		moved := sources{}
+		node := &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{o.inputs.origins.valueToNode(v, orig, o.sort, moved)},
+		}
+		return o.enc.Encode(o.finish(node, orig, moved))
+	}
+
+	o.buf.Reset()
+	if o.seq {
+		o.buf.WriteByte('\x1e')
+	}
+	if s, ok := v.(string); ok && (o.raw || o.join) {
+		o.buf.WriteString(s)
+	} else {
+		o.printer.print(&o.buf, o.inputs.origins.valueToNode(v, orig, o.sort, nil))
+	}
+	if !o.join {
+		o.buf.WriteByte('\n')
+	}
+	if _, err := o.w.Write(o.buf.Bytes()); err != nil {
+		return err
+	}
+	if f, ok := o.w.(flusher); ok && o.unbuffered {
+		return f.Flush()
+	}
+	return nil
+}
+
+type flusher interface {
+	Flush() error
+}
+
+// documentIter iterates over the documents of a list of inputs, one
+// document at a time, converting each into a gojq value. It is also the
+// iterator backing jq's input and inputs builtins.
+type documentIter struct {
+	inputs  []Input
+	origins origins
+	documentOptions
+
+	file     *os.File
+	dec      decoder
+	decoding string
+	path     string
+	name     string
+	document int
+	doc      *yaml.Node
+	err      error
+}
+
+func (it *documentIter) Next() (interface{}, bool) {
+	for it.err == nil {
+		if it.dec == nil {
+			if !it.open() {
+				return nil, false
+			}
+			continue
+		}
+
+		doc := &yaml.Node{}
+		it.document++
+		if err := it.dec.Decode(doc); err != nil {
+			if err == io.EOF {
+				it.close()
+				continue
+			}
+			it.err = inFile(locate(err, it.decoding, it.document), it.name)
+			break
+		}
+		doc, err := it.prepare(doc, it.path, it.document)
+		if err != nil {
+			it.err = inFile(locate(err, it.decoding, it.document), it.name)
+			break
+		}
+		if doc == nil {
+			continue
+		}
+
+		v, err := it.origins.nodeToValue(doc, it.scalars)
+		if err != nil {
+			it.err = inFile(locate(err, it.decoding, it.document), it.name)
+			break
+		}
+		it.doc = doc
+		return v, true
+	}
+	if it.err == io.EOF {
+		return nil, false
+	}
+	err := it.err
+	it.err = io.EOF
+	return err, true
+}
+
+// open starts decoding the next input, returning false once every input has
+// been read.
+func (it *documentIter) open() bool {
+	if len(it.inputs) == 0 {
+		return false
+	}
+
+	input := it.inputs[0]
+	it.inputs = it.inputs[1:]
+	it.path, it.name, it.document = input.Name, input.Name, 0
+	if it.name == "" {
+		it.name = "<stdin>"
+	}
+	r := input.Reader
+	if r == nil {
+		file, err := os.Open(input.Name)
+		if err != nil {
+			it.err = err
+			return true
+		}
+		it.file, r = file, file
+	}
+	it.dec = it.newDecoder(r, fileFormat(input.Name, it.inputFormat))
+	return true
+}
+
+// newDecoder returns the decoder of r, written in format, sniffing the
+// format if it is not set.
+func (it *documentIter) newDecoder(r io.Reader, format string) decoder {
+	if format == "" {
+		r, format = sniffFormat(r)
+	}
+	it.decoding = format
+	return newDecoder(r, format)
+}
+
+func (it *documentIter) close() {
+	if it.file != nil {
+		it.file.Close()
+		it.file = nil
+	}
+	it.dec = nil
+}
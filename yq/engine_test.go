@@ -0,0 +1,435 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEngine(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		yaml            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Pretty prints JSON like jq",
+			[]string{"yq", "."},
+			`{foo: [1, {}], bar: []}`,
+			`{
+  "foo": [
+    1,
+    {}
+  ],
+  "bar": []
+}`,
+			false,
+		},
+		{
+			"Compact output keeps key order of extracted objects",
+			[]string{"yq", "-c", ".spec"},
+			`spec: {zulu: 1, alpha: {yankee: 2, bravo: 3}}`,
+			`{"zulu":1,"alpha":{"yankee":2,"bravo":3}}`,
+			false,
+		},
+		{
+			"New keys are sorted after the original ones",
+			[]string{"yq", "-c", ".c = 1 | .a = 2"},
+			`{zulu: 1, b: 2}`,
+			`{"zulu":1,"b":2,"a":2,"c":1}`,
+			false,
+		},
+		{
+			"Sorts keys",
+			[]string{"yq", "-S", "-c", "."},
+			`{zulu: 1, alpha: 2}`,
+			`{"alpha":2,"zulu":1}`,
+			false,
+		},
+		{
+			"Indents with tabs",
+			[]string{"yq", "--tab", "."},
+			`{foo: [1]}`,
+			"{\n\t\"foo\": [\n\t\t1\n\t]\n}",
+			false,
+		},
+		{
+			"Raw output",
+			[]string{"yq", "-r", ".[]"},
+			`[a, "b\tc", 1]`,
+			"a\nb\tc\n1",
+			false,
+		},
+		{
+			"Colored output",
+			[]string{"yq", "-C", "-c", "."},
+			`{a: [null, "b"]}`,
+			"\x1b[1;39m{\x1b[0m\x1b[34;1m\"a\"\x1b[0m\x1b[1;39m:\x1b[0m" +
+				"\x1b[1;39m[\x1b[1;30mnull\x1b[0m\x1b[1;39m,\x1b[0;32m\"b\"\x1b[0m" +
+				"\x1b[1;39m\x1b[1;39m]\x1b[0m\x1b[1;39m\x1b[1;39m}\x1b[0m",
+			false,
+		},
+		{
+			"Escapes strings like jq",
+			[]string{"yq", "-c", "."},
+			"\"<a>&\\u007f\\u0001é\"",
+			`"<a>&\u007f\u0001é"`,
+			false,
+		},
+		{
+			"Formats numbers like jq",
+			[]string{"yq", "-c", "."},
+			`[1.0, 1e6, 1e17, 0.0001, 0.00001, 12345678901234567890, 1.5]`,
+			`[1,1000000,1e+17,0.0001,1e-05,12345678901234567890,1.5]`,
+			false,
+		},
+		{
+			"Slurps documents",
+			[]string{"yq", "-s", "-c", "map(.a)"},
+			"a: 1\n---\na: 2",
+			`[1,2]`,
+			false,
+		},
+		{
+			"Reads raw inputs whole with --stream, as jq does",
+			[]string{"yq", "-s", "-R", "--stream", "-c", "."},
+			"a: 1\n---\nb: 2",
+			`"{\"a\":1}\n{\"b\":2}\n"`,
+			false,
+		},
+		{
+			"Reads inputs with null input",
+			[]string{"yq", "-n", "-c", "[inputs.a]"},
+			"a: 1\n---\na: 2",
+			`[1,2]`,
+			false,
+		},
+		{
+			"Binds --arg variables",
+			[]string{"yq", "-n", "-c", "--arg", "name", "value", "[$name, $ARGS.named]"},
+			`null`,
+			`["value",{"name":"value"}]`,
+			false,
+		},
+		{
+			"Binds every repeated variable flag",
+			[]string{"yq", "-n", "-c", "--arg", "a", "1", "--arg", "b", "2",
+				"--argjson", "c", `{"d": [3]}`, "[$a, $b, $c, $ARGS.named.b]"},
+			`null`,
+			`["1","2",{"d":[3]},"2"]`,
+			false,
+		},
+		{
+			"Binds variables read from files",
+			[]string{"yq", "-n", "-c",
+				"--slurpfile", "slurped", "test_resources/values.json",
+				"--argfile", "many", "test_resources/values.json",
+				"--argfile", "one", "test_resources/value.json",
+				"--rawfile", "raw", "test_resources/value.json",
+				"[$slurped, $many, $one, $raw]"},
+			`null`,
+			`[[{"a":1},2],[{"a":1},2],{"a":1},"{\"a\": 1}\n"]`,
+			false,
+		},
+		{
+			"Binds positional parameters",
+			[]string{"yq", "-n", "-c", "$ARGS.positional", "--args", "a", "1",
+				"--jsonargs", "1", `{"b": [2]}`},
+			`null`,
+			`["a","1",1,{"b":[2]}]`,
+			false,
+		},
+		{
+			"Emits YAML with comments and key order",
+			[]string{"yq", "-y", ".spec.replicas = 3"},
+			`# deployment
+spec:
+  replicas: 1 # count
+  name: web`,
+			`# deployment
+spec:
+  replicas: 3 # count
+  name: web`,
+			false,
+		},
+		{
+			"Keeps the comments of a value moved by the filter",
+			[]string{"yq", "-y", ".a"},
+			"b: 1 # root b\na:\n  b: 2 # a b\n",
+			"b: 2 # a b",
+			false,
+		},
+		{
+			"Keeps the comments of the items select keeps",
+			[]string{"yq", "-y", "map(select(.x == 2))"},
+			"# one\n- x: 1 # x one\n# two\n- x: 2 # x two\n",
+			"# two\n- x: 2 # x two",
+			false,
+		},
+		{
+			"Keeps the comments of the items reverse moves",
+			[]string{"yq", "-y", "reverse"},
+			"- a: 1 # one\n- 2 # two\n",
+			"- 2 # two\n- a: 1 # one",
+			false,
+		},
+		{
+			"Indents YAML with --indent",
+			[]string{"yq", "-y", "--indent", "4", "."},
+			`{a: {b: [1]}}`,
+			`a:
+    b:
+        - 1`,
+			false,
+		},
+		{
+			"Does not indent lists with --indentless-lists",
+			[]string{"yq", "-y", "--indentless-lists", "."},
+			`{a: [1, {b: [2]}]}`,
+			`a:
+- 1
+- b:
+  - 2`,
+			false,
+		},
+		{
+			"Marks documents with --explicit-start and --explicit-end",
+			[]string{"yq", "-y", "--explicit-start", "--explicit-end", "."},
+			"a: 1\n---\nb: 2",
+			`---
+a: 1
+...
+---
+b: 2
+...`,
+			false,
+		},
+		{
+			"Uses the flow style from --flow-level",
+			[]string{"yq", "-y", "--flow-level", "2", "."},
+			`{a: {b: [1, 2]}, c: [3]}`,
+			`a: {b: [1, 2]}
+c: [3]`,
+			false,
+		},
+		{
+			"Folds long strings with --width",
+			[]string{"yq", "-y", "-w", "20", "."},
+			`{a: one two three four five, b: [six seven eight nine ten],
+c: "x  y z  - - - - -"}`,
+			`a: one two three
+  four five
+b:
+  - six seven eight
+    nine ten
+c: x  y z  - - - - -`,
+			false,
+		},
+		{
+			"Reads XML when run as xq",
+			[]string{"/usr/bin/xq", "-c", ".a.b"},
+			`<a><b id="1">x</b></a>`,
+			`{"@id":"1","#text":"x"}`,
+			false,
+		},
+		{
+			"Emits XML with -x",
+			[]string{"xq", "-x", ".a.b |= ascii_upcase"},
+			`<a><b>x</b></a>`,
+			`<a>
+  <b>X</b>
+</a>`,
+			false,
+		},
+		{
+			"Reads TOML when run as tomlq",
+			[]string{"tomlq", "-c", "."},
+			"title = \"x\"\n[owner]\ndob = 1979-05-27\n",
+			`{"title":"x","owner":{"dob":"1979-05-27"}}`,
+			false,
+		},
+		{
+			"Emits TOML with -t",
+			[]string{"tomlq", "-t", ".owner.name = \"Tom\""},
+			"title = \"x\"\n[owner]\ndob = 1979-05-27\n",
+			`title = "x"
+
+[owner]
+dob = 1979-05-27
+name = "Tom"`,
+			false,
+		},
+		{
+			"Writes only the datetimes the filter did not modify as datetimes",
+			[]string{"tomlq", "-t", ".s = \"1979-05-27\" | .b |= sub(\"28\"; \"29\")"},
+			"a = 1979-05-27\nb = 1979-05-28\n",
+			`a = 1979-05-27
+b = "1979-05-29"
+s = "1979-05-27"`,
+			false,
+		},
+		{
+			"Errors on runtime errors",
+			[]string{"yq", ".a + 1"},
+			`a: foo`,
+			"",
+			true,
+		},
+		{
+			"Errors on aliases whose anchor the filter removed",
+			[]string{"yq", "-y", "--preserve-anchors", "del(.base)"},
+			"base: &b\n  x: 1\ncopy: *b",
+			"",
+			true,
+		},
+		{
+			"Errors on invalid YAML",
+			[]string{"yq", "."},
+			"foo: bar\nfoo: baz",
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			err := y.engine.run(nil, strings.NewReader(tCase.yaml), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected run to return an error and it did not")
+			}
+		})
+	}
+}
+
+func TestEngineExitStatus(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		yaml            string
+		expected        int
+	}
+	testcases := []testCase{
+		{"Succeeds", []string{"yq", "."}, "a: 1", 0},
+		{"Succeeds on null without -e", []string{"yq", ".b"}, "a: 1", 0},
+		{"Exits with 1 when -e outputs null", []string{"yq", "-e", ".b"},
+			"a: 1", 1},
+		{"Exits with 1 when -e outputs false last", []string{"yq", "-e", ".[]"},
+			"[1, false]", 1},
+		{"Succeeds when -e outputs true last", []string{"yq", "-e", ".[]"},
+			"[false, 1]", 0},
+		{"Exits with 4 when -e outputs nothing", []string{"yq", "-e", "empty"},
+			"a: 1", 4},
+		{"Exits with 5 on runtime errors", []string{"yq", ".a + 1"},
+			"a: foo", 5},
+		{"Uses the status of the last input", []string{"yq", "-e", ".a"},
+			"a: false\n---\na: 1", 0},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			err := y.engine.run(nil, strings.NewReader(tCase.yaml), ioutil.Discard)
+			actual := 0
+			if err != nil {
+				exit, ok := err.(*exitError)
+				if !ok {
+					t.Fatal("Expected an exit status got: ", err)
+				}
+				actual = exit.status
+			}
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEngineCompileError(t *testing.T) {
+	var y yq
+	err := y.compileJqCmd([]string{"yq", ".["}, ioutil.Discard)
+	if err == nil {
+		t.Fatal("Expected compileJqCmd to return an error and it did not")
+	}
+	if exit, ok := err.(*exitError); !ok || exit.status != 3 {
+		t.Errorf("Expected exit status 3 got '%v'", err)
+	}
+}
+
+func TestEmptyDocs(t *testing.T) {
+	input := "a: 1\n---\n--- null\n---\nb: 2\n"
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Skips empty and null documents by default",
+			[]string{"yq", "-c", "-s", "."},
+			"[{\"a\":1},{\"b\":2}]\n",
+		},
+		{
+			"Reads empty and null documents as null",
+			[]string{"yq", "-c", "-s", "--empty-docs", "null", "."},
+			"[{\"a\":1},null,null,{\"b\":2}]\n",
+		},
+		{
+			"Counts empty documents",
+			[]string{"yq", "-n", "--empty-docs", "null", "[inputs] | length"},
+			"4\n",
+		},
+		{
+			"Writes null documents in YAML",
+			[]string{"yq", "-y", "--empty-docs", "null", "."},
+			"a: 1\n---\nnull\n---\nnull\n---\nb: 2\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			if err := y.engine.run(nil, strings.NewReader(input), &b); err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+			if tCase.expected != b.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, b.String())
+			}
+		})
+	}
+
+	var b bytes.Buffer
+	et := jqPipe{documentOptions: documentOptions{emptyDocs: "null"}}
+	if err := et.toJSON(strings.NewReader(input), &b); err != nil {
+		t.Fatal("Did not expect an error got: ", err)
+	}
+	expected := "{\"a\":1}\nnull\nnull\n{\"b\":2}\n"
+	if expected != b.String() {
+		t.Errorf("Expected '%v' got '%v'", expected, b.String())
+	}
+}
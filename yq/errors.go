@@ -0,0 +1,149 @@
+package yq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// exitError makes yq exit with the given status, the way jq would. Its
+// message is empty when the error was already reported on stderr.
+type exitError struct {
+	status  int
+	message string
+}
+
+func (e *exitError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("exit status %d", e.status)
+	}
+	return e.message
+}
+
+// CompileError is an error compiling a filter.
+type CompileError struct {
+	Err error
+}
+
+func (e *CompileError) Error() string {
+	return "jq: error: " + e.Err.Error()
+}
+
+// RuntimeError is an error the filter raised running on a document of the
+// named input.
+type RuntimeError struct {
+	File string
+	Err  error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("jq: error (at %s): %v", e.File, e.Err)
+}
+
+// DecodeError is an error reading a document, with its location in the
+// input: the file, the index of the document in it starting at 1, and the
+// line and column of the problem. Unknown parts of the location are left
+// zero. The format of the document is yaml unless set.
+type DecodeError struct {
+	Format   string
+	File     string
+	Document int
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (e *DecodeError) Error() string {
+	var location []string
+	if e.Document != 0 {
+		location = append(location, "document "+strconv.Itoa(e.Document))
+	}
+	if e.Line != 0 {
+		location = append(location, "line "+strconv.Itoa(e.Line))
+	}
+	if e.Column != 0 {
+		location = append(location, "column "+strconv.Itoa(e.Column))
+	}
+
+	prefix := "yaml: "
+	if e.Format != "" {
+		prefix = e.Format + ": "
+	}
+	if e.File != "" {
+		prefix += e.File + ": "
+	}
+	if len(location) > 0 {
+		prefix += strings.Join(location, ", ") + ": "
+	}
+	return prefix + e.Message
+}
+
+// nodeError returns an error about node, located at its line and column.
+func nodeError(node *yaml.Node, format string, a ...interface{}) error {
+	return &DecodeError{Line: node.Line, Column: node.Column,
+		Message: fmt.Sprintf(format, a...)}
+}
+
+// yamlLine matches the location yaml.v3 prefixes its syntax errors with,
+// which only holds the line.
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// locate returns err, an error decoding the given document of an input in
+// format, as a DecodeError.
+func locate(err error, format string, document int) *DecodeError {
+	e, ok := err.(*DecodeError)
+	if !ok {
+		e = &DecodeError{Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Message = err.Error()[len(m[0]):]
+		}
+	}
+	if e.Format == "" {
+		e.Format = format
+	}
+	e.Document = document
+	return e
+}
+
+// inFile records that err, if it is a DecodeError, happened reading the
+// named file.
+func inFile(err error, name string) error {
+	if e, ok := err.(*DecodeError); ok {
+		e.File = name
+	}
+	return err
+}
+
+// errorReport is an error formatted with --error-format=json.
+type errorReport struct {
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Document int    `json:"document,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// reportError writes err to w in the given format, either text or json,
+// where it is a JSON object on a single line.
+func reportError(w io.Writer, err error, format string) {
+	if format != "json" {
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	report := errorReport{Message: err.Error()}
+	if e, ok := err.(*DecodeError); ok {
+		report = errorReport{e.Message, e.File, e.Document, e.Line, e.Column}
+	}
+	// Like jq, and unlike json.Marshal, leave <, > and & unescaped, as in
+	// <stdin>. Encode terminates the object with a newline.
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(report)
+}
@@ -0,0 +1,95 @@
+package yq
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeErrorLocation(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		yaml            string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Locates syntax errors by document and line",
+			"a: 1\n---\nb: [1\nc: 2",
+			`yaml: foo.yaml: document 2, line 2: did not find expected ',' or ']'`,
+		},
+		{
+			"Locates duplicate keys by line and column",
+			"a: 1\n---\nb:\n  c: 1\n  c: 2",
+			`yaml: foo.yaml: document 2, line 5, column 3: mapping key "c" ` +
+				`already defined at line 4`,
+		},
+		{
+			"Counts null documents",
+			"a: 1\n---\n---\nb: .nan",
+			`yaml: foo.yaml: document 3, line 4, column 4: .nan cannot be ` +
+				`represented in JSON`,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b buffer
+			err := transformToJSON(strings.NewReader(tCase.yaml), &b)
+			if err == nil {
+				t.Fatal("Expected transformToJSON to return an error and it did not")
+			}
+
+			actual := inFile(err, "foo.yaml").Error()
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReportError(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		err             error
+		format          string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Reports errors as text",
+			&DecodeError{File: "foo.yaml", Document: 1, Line: 2, Message: "bad"},
+			"text",
+			"yaml: foo.yaml: document 1, line 2: bad\n",
+		},
+		{
+			"Reports decode errors as JSON",
+			&DecodeError{File: "foo.yaml", Document: 1, Line: 2, Column: 3,
+				Message: "bad"},
+			"json",
+			`{"message":"bad","file":"foo.yaml","document":1,"line":2,"column":3}` +
+				"\n",
+		},
+		{
+			"Reports the file name of stdin unescaped",
+			&DecodeError{File: "<stdin>", Document: 1, Message: "a & b"},
+			"json",
+			`{"message":"a & b","file":"<stdin>","document":1}` + "\n",
+		},
+		{
+			"Reports other errors as JSON",
+			errors.New("bad"),
+			"json",
+			`{"message":"bad"}` + "\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			reportError(&b, tCase.err, tCase.format)
+			if tCase.expected != b.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, b.String())
+			}
+		})
+	}
+}
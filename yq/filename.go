@@ -0,0 +1,55 @@
+package yq
+
+import (
+	"strconv"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// withFilename returns doc, the nth document of the named file, as the
+// document of an object holding the file, the index of the document and its
+// value, for --with-filename. The file of stdin is null, as jq's
+// input_filename has it.
+func withFilename(doc *yaml.Node, file string, n int) *yaml.Node {
+	name := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	if file != "" {
+		name = stringNode(file)
+	}
+	object := mappingNode(resolve(doc),
+		pair{"file", name},
+		pair{"doc", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int",
+			Value: strconv.Itoa(n)}},
+		pair{"value", resolve(doc)})
+	wrapped := &yaml.Node{Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{object}}
+	wrapped.HeadComment, wrapped.FootComment = doc.HeadComment, doc.FootComment
+	return wrapped
+}
+
+// withoutFilename returns the document of the value of the object doc
+// holds, if it is shaped like the objects of withFilename, and otherwise
+// doc. The results of filters that keep the object are written as the
+// documents they wrap.
+func withoutFilename(doc *yaml.Node) *yaml.Node {
+	object := resolve(doc)
+	if object == nil || object.Kind != yaml.MappingNode ||
+		len(object.Content) != 6 {
+		return doc
+	}
+	var value *yaml.Node
+	keys := map[string]bool{}
+	for i := 0; i < len(object.Content); i += 2 {
+		key := object.Content[i].Value
+		keys[key] = true
+		if key == "value" {
+			value = object.Content[i+1]
+		}
+	}
+	if !keys["file"] || !keys["doc"] || value == nil {
+		return doc
+	}
+	unwrapped := &yaml.Node{Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{value}}
+	unwrapped.HeadComment, unwrapped.FootComment = doc.HeadComment, doc.FootComment
+	return unwrapped
+}
@@ -0,0 +1,80 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithFilename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.yaml")
+	if err := ioutil.WriteFile(path, []byte("# head\na: 1\n---\nb: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		files           []string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Wraps each document with its file and index",
+			[]string{"yq", "-c", "--with-filename", "."},
+			[]string{path},
+			`{"file":"` + path + `","doc":1,"value":{"a":1}}` + "\n" +
+				`{"file":"` + path + `","doc":2,"value":{"b":2}}`,
+		},
+		{
+			"Shows null as the file of stdin",
+			[]string{"yq", "-c", "--with-filename", "."},
+			nil,
+			`{"file":null,"doc":1,"value":{"x":1}}`,
+		},
+		{
+			"Writes the values of the objects with -y",
+			[]string{"yq", "-y", "--with-filename", ".value.c = .doc"},
+			[]string{path},
+			"# head\na: 1\nc: 1\n---\nb: 2\nc: 2",
+		},
+		{
+			"Writes the results of other filters as they are with -y",
+			[]string{"yq", "-y", "--with-filename", "{file: .file}"},
+			nil,
+			"file: null",
+		},
+		{
+			"Defines input_filename",
+			[]string{"yq", "-c", "[input_filename, .]"},
+			[]string{path},
+			`["` + path + `",{"a":1}]` + "\n" + `["` + path + `",{"b":2}]`,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			err := y.engine.run(tCase.files, strings.NewReader("x: 1\n"), &b)
+			if err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+			actual := strings.Trim(b.String(), "\r\n")
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package yq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errHelp is returned by parseFlags when help was requested with -h.
+var errHelp = errors.New("help requested")
+
+// errVersion is returned by parseFlags when --version was requested.
+var errVersion = errors.New("version requested")
+
+// option is a command line option, described the way jq's own argument
+// parser sees them: a short and/or a long name, and the parameters that
+// follow it.
+type option struct {
+	short  byte
+	long   string
+	params []string
+	usage  string
+	apply  func(params []string) error
+}
+
+func boolOption(short byte, long string, v *bool, usage string) option {
+	return option{short: short, long: long, usage: usage,
+		apply: func([]string) error {
+			*v = true
+			return nil
+		}}
+}
+
+func (yq *yq) options() []option {
+	return []option{
+		boolOption('y', "yaml-output", &yq.returnYAML, "transcode jq JSON "+
+			"output back into YAML and emit it"),
+		{short: 'x', long: "xml-output", usage: "transcode jq JSON output " +
+			"back into XML and emit it",
+			apply: func([]string) error {
+				yq.returnYAML, yq.format.syntax = true, "xml"
+				return nil
+			}},
+		{long: "xml-root", params: []string{"name"}, usage: "with -x, wrap " +
+			"each document in an element called name",
+			apply: func(params []string) error {
+				yq.format.xmlRoot = params[0]
+				return nil
+			}},
+		{short: 't', long: "toml-output", usage: "transcode jq JSON output " +
+			"back into TOML and emit it",
+			apply: func([]string) error {
+				yq.returnYAML, yq.format.syntax = true, "toml"
+				return nil
+			}},
+		boolOption('i', "in-place", &yq.inPlace, "edit the files in place, "+
+			"writing the YAML result back to each of them"),
+		boolOption(0, "per-file", &yq.perFile, "run the filter separately "+
+			"over each file, prefixing its output with the file name"),
+		{long: "jobs", params: []string{"n"}, usage: "with --per-file or -i, " +
+			"filter n files at once",
+			apply: func(params []string) error {
+				n, err := strconv.Atoi(params[0])
+				if err != nil || n <= 0 {
+					return fmt.Errorf("--jobs takes a positive number, got %q",
+						params[0])
+				}
+				yq.jobs = n
+				return nil
+			}},
+		{short: 'w', long: "width", params: []string{"n"}, usage: "with -y, " +
+			"fold long strings to fit in n columns",
+			apply: func(params []string) error {
+				n, err := strconv.Atoi(params[0])
+				if err != nil || n <= 0 {
+					return fmt.Errorf("--width takes a positive number, got %q",
+						params[0])
+				}
+				yq.format.width = n
+				return nil
+			}},
+		{long: "flow-level", params: []string{"n"}, usage: "with -y, use the " +
+			"flow style for collections nested n levels deep, 1 being the root",
+			apply: func(params []string) error {
+				n, err := strconv.Atoi(params[0])
+				if err != nil || n <= 0 {
+					return fmt.Errorf("--flow-level takes a positive number, "+
+						"got %q", params[0])
+				}
+				yq.format.flowLevel = n
+				return nil
+			}},
+		boolOption(0, "explicit-start", &yq.format.explicitStart, "with -y, "+
+			"start every document with ---"),
+		boolOption(0, "explicit-end", &yq.format.explicitEnd, "with -y, end "+
+			"every document with ..."),
+		boolOption(0, "indentless-lists", &yq.format.indentlessLists, "with "+
+			"-y, do not indent lists inside mappings"),
+		boolOption(0, "preserve-tags", &yq.properties.tags, "show the custom "+
+			"tags of YAML nodes as {\"$tag\", \"$value\"} objects, tags again with -y"),
+		boolOption(0, "preserve-anchors", &yq.properties.anchors, "show "+
+			"anchors and aliases as {\"$anchor\", \"$value\"} and {\"$alias\"} "+
+			"objects, which -y writes back"),
+		boolOption(0, "with-filename", &yq.withFilename, "show each "+
+			"document as {\"file\", \"doc\", \"value\"}, its value alone with -y"),
+		{long: "merge-keys", params: []string{"mode"}, usage: "expand " +
+			"YAML merge keys (<<) into their mapping, preserve them as \"<<\" " +
+			"keys or reject them",
+			apply: choice("--merge-keys", &yq.mergeKeys,
+				"expand", "preserve", "reject")},
+		{long: "key-policy", params: []string{"policy"}, usage: "turn " +
+			"mapping keys that are not strings into strings, skip them or " +
+			"reject them, the default",
+			apply: choice("--key-policy", &yq.keyPolicy,
+				"stringify", "skip", "error")},
+		boolOption(0, "restore-int-keys", &yq.restoreIntKeys, "with -y, "+
+			"write the keys that are decimal integers as integers"),
+		{long: "special-floats", params: []string{"policy"}, usage: "turn " +
+			".inf, -.inf and .nan into strings or null, or reject them, the " +
+			"default",
+			apply: choice("--special-floats", &yq.scalars.specialFloats,
+				"string", "null", "error")},
+		{long: "timestamps", params: []string{"policy"}, usage: "turn " +
+			"timestamps into RFC 3339 strings, the default, or into seconds " +
+			"since the epoch",
+			apply: choice("--timestamps", &yq.scalars.timestamps,
+				"string", "epoch")},
+		{long: "binary", params: []string{"policy"}, usage: "turn !!binary " +
+			"values into the string they decode to, the default, or keep " +
+			"their base64",
+			apply: choice("--binary", &yq.scalars.binary, "string", "base64")},
+		{long: "empty-docs", params: []string{"policy"}, usage: "skip " +
+			"empty and null documents, the default, or read them as null",
+			apply: choice("--empty-docs", &yq.emptyDocs, "skip", "null")},
+		{long: "input-format", params: []string{"format"}, usage: "read " +
+			"the inputs as yaml, json, toml, xml or csv, or detect their format " +
+			"with auto",
+			apply: func(params []string) error {
+				err := choice("--input-format", &yq.inputFormat,
+					"yaml", "json", "toml", "xml", "csv", "auto")(params)
+				if yq.inputFormat == "auto" {
+					yq.inputFormat = ""
+				}
+				return err
+			}},
+		boolOption(0, "recursive", &yq.recursive, "read the files under the "+
+			"directories given, of the input format or any format yq reads"),
+		{long: "include", params: []string{"pattern"}, usage: "read only " +
+			"the files of directories and glob patterns matching pattern",
+			apply: yq.pattern(&yq.include)},
+		{long: "exclude", params: []string{"pattern"}, usage: "skip the " +
+			"files and directories matching pattern",
+			apply: yq.pattern(&yq.exclude)},
+		{long: "jq-binary", params: []string{"path"}, usage: "run the filter " +
+			"with the given jq executable instead of the embedded jq",
+			apply: func(params []string) error {
+				yq.jqBinary = params[0]
+				return nil
+			}},
+		{long: "error-format", params: []string{"format"}, usage: "report " +
+			"errors as text or as json, one object per line",
+			apply: choice("--error-format", &yq.errorFormat, "text", "json")},
+		boolOption('c', "compact-output", &yq.compact, "compact instead of "+
+			"pretty-printed output"),
+		boolOption('n', "null-input", &yq.nullAsSingleInputValue, "use `null` "+
+			"as the single input value"),
+		boolOption('e', "exit-status", &yq.exitStatusCodeBasedOnOutput, "set "+
+			"the exit status code based on the output"),
+		boolOption('s', "slurp", &yq.slurp, "read (slurp) all inputs into an "+
+			"array; apply filter to it"),
+		boolOption('r', "raw-output", &yq.raw, "output raw strings, not JSON "+
+			"texts"),
+		boolOption('j', "join-output", &yq.join, "like -r, without a newline "+
+			"after each output"),
+		boolOption('a', "ascii-output", &yq.ascii, "escape non-ASCII "+
+			"characters in JSON output"),
+		boolOption('R', "raw-input", &yq.rawString, "read raw strings, not "+
+			"JSON texts"),
+		boolOption('C', "color-output", &yq.color, "colorize JSON"),
+		boolOption('M', "monochrome-output", &yq.monochrome, "monochrome "+
+			"(don't colorize JSON)"),
+		boolOption('S', "sort-keys", &yq.sort, "sort keys of objects on output"),
+		boolOption(0, "tab", &yq.tab, "use tabs for indentation"),
+		{long: "indent", params: []string{"n"}, usage: "use n spaces for " +
+			"indentation (max 7), of the YAML too with -y",
+			apply: func(params []string) error {
+				n, err := strconv.Atoi(params[0])
+				if err != nil || n < 0 {
+					return fmt.Errorf("--indent takes a non-negative number, "+
+						"got %q", params[0])
+				}
+				if n > 7 {
+					return errors.New("Cannot indent more than 7 characters")
+				}
+				yq.indent, yq.tab = n, false
+				return nil
+			}},
+		boolOption(0, "seq", &yq.seq, "prefix each output with the ASCII "+
+			"record separator"),
+		boolOption(0, "stream", &yq.stream, "parse the input in streaming "+
+			"fashion"),
+		boolOption(0, "unbuffered", &yq.unbuffered, "flush the output after "+
+			"each JSON text"),
+		boolOption('f', "from-file", &yq.fromFile, "read the filter from the "+
+			"file named by the first argument"),
+		{short: 'L', params: []string{"dir"}, usage: "search modules in " +
+			"the directory",
+			apply: func(params []string) error {
+				yq.libraryPaths = append(yq.libraryPaths, params[0])
+				return nil
+			}},
+		{long: "arg", params: []string{"a", "v"}, usage: "set variable $a " +
+			"to value <v>",
+			apply: yq.variable("--arg")},
+		{long: "argjson", params: []string{"a", "v"}, usage: "set variable " +
+			"$a to JSON value <v>",
+			apply: yq.variable("--argjson")},
+		{long: "slurpfile", params: []string{"a", "f"}, usage: "set variable " +
+			"$a to an array of JSON texts read from <f>",
+			apply: yq.variable("--slurpfile")},
+		{long: "argfile", params: []string{"a", "f"}, usage: "like " +
+			"--slurpfile, but set $a to the JSON text itself if <f> holds only one",
+			apply: yq.variable("--argfile")},
+		{long: "rawfile", params: []string{"a", "f"}, usage: "set variable " +
+			"$a to a string consisting of the contents of <f>",
+			apply: yq.variable("--rawfile")},
+		{long: "args", usage: "remaining arguments are string arguments, " +
+			"not files",
+			apply: func([]string) error {
+				yq.positionalFlag = "--args"
+				return nil
+			}},
+		{long: "jsonargs", usage: "remaining arguments are JSON arguments, " +
+			"not files",
+			apply: func([]string) error {
+				yq.positionalFlag = "--jsonargs"
+				return nil
+			}},
+		{short: 'h', long: "help", usage: "show this help",
+			apply: func([]string) error {
+				return errHelp
+			}},
+		{short: 'V', long: "version", usage: "show the version",
+			apply: func([]string) error {
+				return errVersion
+			}},
+	}
+}
+
+// variable returns the function applying flag, one of the options defining
+// a variable. Every occurrence of the option is kept, in command line order.
+func (yq *yq) variable(flag string) func([]string) error {
+	return func(params []string) error {
+		v := jqVariable{flag, params[0], params[1]}
+		if flag == "--argjson" {
+			if _, err := v.bind(); err != nil {
+				return err
+			}
+		}
+		yq.variables = append(yq.variables, v)
+		return nil
+	}
+}
+
+// pattern returns the function applying an option that adds a glob pattern
+// to patterns, after checking it.
+func (yq *yq) pattern(patterns *[]string) func([]string) error {
+	return func(params []string) error {
+		if err := checkPattern(strings.Split(params[0], "/")); err != nil {
+			return fmt.Errorf("%s: %v", params[0], err)
+		}
+		*patterns = append(*patterns, params[0])
+		return nil
+	}
+}
+
+// choice returns the apply function of an option whose parameter is one of
+// choices, which it stores in value.
+func choice(option string, value *string, choices ...string) func([]string) error {
+	return func(params []string) error {
+		for _, c := range choices {
+			if params[0] == c {
+				*value = c
+				return nil
+			}
+		}
+		return fmt.Errorf("%s takes %s or %s, got %q", option,
+			strings.Join(choices[:len(choices)-1], ", "), choices[len(choices)-1],
+			params[0])
+	}
+}
+
+// parseFlags parses the command line the way jq does: short options can be
+// clustered (-sc), options can appear before or after the filter and --
+// ends option processing. It returns the filter followed by the files;
+// arguments following --args or --jsonargs are positional parameters
+// instead, which are added to yq.positional.
+func (yq *yq) parseFlags(osArgs []string, stderr io.Writer) ([]string, error) {
+	name := filepath.Base(osArgs[0])
+	yq.indent = 2
+	yq.jobs = 1
+	// Like kislyuk/yq, yq reads XML when it is run as xq, and TOML when it
+	// is run as tomlq.
+	switch name {
+	case "xq":
+		yq.inputFormat = "xml"
+	case "tomlq":
+		yq.inputFormat = "toml"
+	}
+
+	if len(osArgs) == 1 {
+		yq.usage(stderr, name)
+		return nil, usageError("%s: no filter given", name)
+	}
+
+	options := yq.options()
+	unknown := func(text string) error {
+		return usageError("%s: Unknown option: %s\nUse %s --help for help "+
+			"with command-line options,\nor see the jq manpage, or online "+
+			"docs  at https://stedolan.github.io/jq", name, text, name)
+	}
+
+	var positional []string
+	args := osArgs[1:]
+	optionsDone := false
+	for i := 0; i < len(args); i++ {
+		text := args[i]
+		switch {
+		case optionsDone || text == "-" || !strings.HasPrefix(text, "-"):
+			if len(positional) == 0 || yq.positionalFlag == "" {
+				positional = append(positional, text)
+				continue
+			}
+			p := jqPositional{yq.positionalFlag, text}
+			if _, err := p.bind(); err != nil {
+				return nil, prefixError(name, err)
+			}
+			yq.positional = append(yq.positional, p)
+		case text == "--":
+			optionsDone = true
+		case strings.HasPrefix(text, "--"):
+			long, value := text[2:], ""
+			hasValue := false
+			if j := strings.IndexByte(long, '='); j >= 0 {
+				long, value, hasValue = long[:j], long[j+1:], true
+			}
+
+			opt := findOption(options, func(o option) bool { return o.long == long })
+			if opt == nil || hasValue && len(opt.params) != 1 {
+				return nil, unknown(text)
+			}
+
+			params := []string{value}
+			if !hasValue {
+				var err error
+				if params, err = takeParams(name, text, opt, args[i+1:]); err != nil {
+					return nil, err
+				}
+				i += len(params)
+			}
+			if err := opt.apply(params); err != nil {
+				return nil, prefixError(name, err)
+			}
+		default:
+			for j := 1; j < len(text); j++ {
+				c := text[j]
+				opt := findOption(options, func(o option) bool { return o.short == c })
+				if opt == nil {
+					return nil, unknown(text)
+				}
+
+				var params []string
+				if len(opt.params) > 0 {
+					// The rest of the cluster, as in -L/usr/lib/jq, is the
+					// first parameter.
+					rest := args[i+1:]
+					if j+1 < len(text) {
+						rest = append([]string{text[j+1:]}, rest...)
+						i--
+					}
+					var err error
+					if params, err = takeParams(name, text, opt, rest); err != nil {
+						return nil, err
+					}
+					i += len(params)
+					j = len(text)
+				}
+				if err := opt.apply(params); err != nil {
+					return nil, prefixError(name, err)
+				}
+			}
+		}
+	}
+
+	if len(positional) == 0 {
+		yq.usage(stderr, name)
+		return nil, usageError("%s: no filter given", name)
+	}
+	return positional, nil
+}
+
+func findOption(options []option, match func(option) bool) *option {
+	for i := range options {
+		if match(options[i]) {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+func takeParams(name, text string, opt *option, args []string) ([]string, error) {
+	if len(args) < len(opt.params) {
+		return nil, usageError("%s: %s takes %d parameter(s) (e.g. %s %s)",
+			name, text, len(opt.params), text, strings.Join(opt.params, " "))
+	}
+	return args[:len(opt.params)], nil
+}
+
+func prefixError(name string, err error) error {
+	if err == errHelp || err == errVersion {
+		return err
+	}
+	return usageError("%s: %v", name, err)
+}
+
+// usageError is an error in the command line, for which jq exits with
+// status 2.
+func usageError(format string, a ...interface{}) error {
+	return &exitError{status: 2, message: fmt.Sprintf(format, a...)}
+}
+
+// usage prints the options yq understands, in the format of jq's help.
+func (yq *yq) usage(w io.Writer, name string) {
+	fmt.Fprintf(w, "Usage:\t%s [options] <jq filter> [file...]\n\n", name)
+	input := "YAML"
+	if yq.inputFormat != "" {
+		input = strings.ToUpper(yq.inputFormat)
+	}
+	fmt.Fprintf(w, "%s applies a jq filter to %s documents, emitting the "+
+		"results as JSON\nor, with -y, as YAML.\n\nOptions:\n", name, input)
+	for _, opt := range yq.options() {
+		var names []string
+		if opt.short != 0 {
+			names = append(names, "-"+string(opt.short))
+		}
+		if opt.long != "" {
+			names = append(names, "--"+opt.long)
+		}
+		flag := strings.Join(append([]string{strings.Join(names, ", ")},
+			opt.params...), " ")
+		fmt.Fprintf(w, "  %-26s %s;\n", flag, opt.usage)
+	}
+}
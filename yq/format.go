@@ -0,0 +1,159 @@
+package yq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// inputFormats are the formats yq reads, by the extension of the files
+// written in them.
+var inputFormats = map[string]string{
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+	".toml": "toml",
+	".xml":  "xml",
+	".csv":  "csv",
+}
+
+// fileFormat returns the format of the documents of the file at path:
+// format if it is set, by --input-format or the name yq runs as, or else
+// the format its extension stands for. It returns an empty format when the
+// format of the file can only be told from its content.
+func fileFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	return inputFormats[strings.ToLower(filepath.Ext(path))]
+}
+
+// sniffLength is how much of an input sniffFormat looks at at most.
+const sniffLength = 64 << 10
+
+var (
+	tomlTableHeader = regexp.MustCompile(`^\[\[?[\w.\-"' ]+\]\]?\s*(#.*)?$`)
+	tomlKeyValue    = regexp.MustCompile(`^[\w.\-"' ]+=`)
+)
+
+// sniffFormat tells the format of r from its first bytes, returning a
+// reader that still yields them: XML starts with an element or a
+// declaration, JSON with an object or an array that decodes as JSON, and
+// TOML with a table header or a key/value pair. XML and TOML must also
+// parse as such, and TOML is YAML when YAML reads a collection from it,
+// such as the list of [section]. Anything else is YAML.
+//
+// Only the bytes a first read got are looked at, reading on past blank
+// ones, so that inputs still being written, such as a pipe from tail -f,
+// are not waited on. They are taken for the whole input unless they fill
+// sniffLength.
+func sniffFormat(r io.Reader) (io.Reader, string) {
+	br := bufio.NewReaderSize(r, sniffLength)
+	var prefix, content []byte
+	for {
+		_, err := br.Peek(len(prefix) + 1)
+		prefix, _ = br.Peek(br.Buffered())
+		content = bytes.TrimLeft(bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf")), " \t\r\n")
+		if err != nil || len(content) > 0 {
+			break
+		}
+	}
+	truncated := len(prefix) == sniffLength
+	if len(content) == 0 {
+		return br, "yaml"
+	}
+
+	switch content[0] {
+	case '<':
+		if isXML(content, truncated) {
+			return br, "xml"
+		}
+	case '{', '[':
+		var v json.RawMessage
+		err := json.NewDecoder(bytes.NewReader(content)).Decode(&v)
+		if err == nil || truncated && err == io.ErrUnexpectedEOF {
+			return br, "json"
+		}
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if (tomlTableHeader.MatchString(line) || tomlKeyValue.MatchString(line)) &&
+			isTOML(content, truncated) && !isYAMLCollection(content) {
+			return br, "toml"
+		}
+		break
+	}
+	return br, "yaml"
+}
+
+// isXML reports whether content, which is cut short if truncated, is an
+// XML document.
+func isXML(content []byte, truncated bool) bool {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	elements := false
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return elements
+		}
+		if err, ok := err.(*xml.SyntaxError); ok {
+			return truncated && elements && err.Msg == "unexpected EOF"
+		}
+		if err != nil {
+			return false
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			elements = true
+		}
+	}
+}
+
+// isTOML reports whether content, which is cut short if truncated, is a
+// TOML document. A truncated content is read up to its last line, and
+// parse errors on that line are taken for the end of the content.
+func isTOML(content []byte, truncated bool) bool {
+	if truncated {
+		if i := bytes.LastIndexByte(content, '\n'); i >= 0 {
+			content = content[:i+1]
+		}
+	}
+	_, err := toml.Decode(string(content), new(map[string]interface{}))
+	if err, ok := err.(toml.ParseError); ok && truncated {
+		return err.Position.Line >= bytes.Count(content, []byte("\n"))
+	}
+	return err == nil
+}
+
+// isYAMLCollection reports whether content is YAML and its first document
+// is a mapping or a sequence.
+func isYAMLCollection(content []byte) bool {
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	var first yaml.Node
+	if dec.Decode(&first) != nil || len(first.Content) == 0 {
+		return false
+	}
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false
+		}
+	}
+	kind := first.Content[0].Kind
+	return kind == yaml.MappingNode || kind == yaml.SequenceNode
+}
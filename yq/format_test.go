@@ -0,0 +1,93 @@
+package yq
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileFormat(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		path            string
+		format          string
+		expected        string
+	}
+	testcases := []testCase{
+		{"Reads .yml files as YAML", "a/values.yml", "", "yaml"},
+		{"Reads .JSON files as JSON", "values.JSON", "", "json"},
+		{"Reads .csv files as CSV", "people.csv", "", "csv"},
+		{"Sniffs files with other extensions", "values.txt", "", ""},
+		{"Prefers the format set", "values.json", "xml", "xml"},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			actual := fileFormat(tCase.path, tCase.format)
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		input           string
+		expected        string
+	}
+	testcases := []testCase{
+		{"Sniffs JSON objects", "\n {\"a\": 1}\n{\"a\": 2}", "json"},
+		{"Sniffs JSON arrays", `[1, "a"]`, "json"},
+		{"Sniffs YAML flow sequences", `[a, b]`, "yaml"},
+		{"Sniffs YAML flow mappings", `{a: 1}`, "yaml"},
+		{"Sniffs XML", `<?xml version="1.0"?><a/>`, "xml"},
+		{"Sniffs TOML key/value pairs", "# config\nname = \"x\"\n", "toml"},
+		{"Sniffs TOML tables", "[[servers]]\nip = \"10.0.0.1\"\n", "toml"},
+		{"Sniffs YAML", "# config\nname: x = y\n", "yaml"},
+		{"Sniffs strings that are not TOML as YAML", "a = b\n", "yaml"},
+		{"Sniffs lists that are TOML tables as YAML", "[section]\n", "yaml"},
+		{"Sniffs merge keys as YAML", "<<: {a: 1}\nb: 2\n", "yaml"},
+		{"Sniffs XML cut short", "<a>\n  <b>" + strings.Repeat("x", sniffLength), "xml"},
+		{"Sniffs TOML cut short", "a = \"\"\"\n" + strings.Repeat("x", sniffLength), "toml"},
+		{"Sniffs empty inputs as YAML", "", "yaml"},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			r, actual := sniffFormat(strings.NewReader(tCase.input))
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+			data, err := ioutil.ReadAll(r)
+			if err != nil || string(data) != tCase.input {
+				t.Errorf("Expected the reader to yield '%v' got '%v'",
+					tCase.input, string(data))
+			}
+		})
+	}
+}
+
+func TestSniffFormatDoesNotWait(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	go func() {
+		w.Write([]byte("\n"))
+		w.Write([]byte("{\"a\": 1}\n"))
+	}()
+
+	sniffed := make(chan string, 1)
+	go func() {
+		_, format := sniffFormat(r)
+		sniffed <- format
+	}()
+	select {
+	case format := <-sniffed:
+		if format != "json" {
+			t.Errorf("Expected '%v' got '%v'", "json", format)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected sniffFormat to return, it is still waiting on the input")
+	}
+}
@@ -0,0 +1,210 @@
+package yq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// addInput adds the files arg stands for to yq.files: arg itself if it is a
+// file, the files under it if it is a directory and --recursive is given,
+// or else the files matching it as a glob pattern. Files found in
+// directories or by patterns are added in lexical order, once, if they pass
+// --include and --exclude; seen records those already added.
+func (yq *yq) addInput(arg string, seen map[string]bool) error {
+	info, err := os.Stat(arg)
+	if err == nil {
+		if info.IsDir() {
+			return yq.addDir(arg, seen)
+		}
+		yq.files = append(yq.files, arg)
+		return nil
+	}
+	if !hasMeta(arg) {
+		return err
+	}
+
+	matches, err := glob(arg)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("%s: no files match", arg)
+	}
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !info.IsDir():
+			if yq.selected(path, false) && !seen[path] {
+				seen[path] = true
+				yq.files = append(yq.files, path)
+			}
+		case yq.recursive:
+			if err := yq.addDir(path, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addDir adds the files under dir, with --recursive.
+func (yq *yq) addDir(dir string, seen map[string]bool) error {
+	if !yq.recursive {
+		return fmt.Errorf("%s: is a directory, use --recursive to read "+
+			"the files under it", dir)
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && yq.excluded(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if yq.selected(path, true) && !seen[path] {
+			seen[path] = true
+			yq.files = append(yq.files, path)
+		}
+		return nil
+	})
+}
+
+// selected tells whether the file at path, found in a directory or by a
+// pattern, is read: it must match one of the --include patterns, if any,
+// and none of the --exclude ones. Without --include, only the files of the
+// input format, or of a format yq reads when it is not set, are read from
+// directories.
+func (yq *yq) selected(path string, inDir bool) bool {
+	if yq.excluded(path) {
+		return false
+	}
+	if len(yq.include) == 0 {
+		format := inputFormats[strings.ToLower(filepath.Ext(path))]
+		return !inDir || format != "" && (yq.inputFormat == "" || yq.inputFormat == format)
+	}
+	for _, pattern := range yq.include {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (yq *yq) excluded(path string) bool {
+	for _, pattern := range yq.exclude {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMeta tells whether path holds any of the special characters of glob
+// patterns.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// glob returns the paths matching pattern, in lexical order. Besides the
+// syntax of filepath.Match, a ** segment matches any number of directories.
+// As in the shell, wildcards do not match names starting with a dot.
+func glob(pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(segments) && !hasMeta(segments[i]) {
+		i++
+	}
+	root, rest := filepath.FromSlash(strings.Join(segments[:i], "/")), segments[i:]
+	switch {
+	case i == 0:
+		root = "."
+	case root == "":
+		root = string(filepath.Separator)
+	}
+	if err := checkPattern(rest); err != nil {
+		return nil, fmt.Errorf("%s: %v", pattern, err)
+	}
+
+	var matches []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := strings.Split(filepath.ToSlash(rel), "/")
+		if matchSegments(rest, name, false, false) {
+			matches = append(matches, path)
+		}
+		if info.IsDir() && !matchSegments(rest, name, true, false) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchPath tells whether pattern, a glob pattern as glob takes them,
+// matches path or its end: a pattern without a slash matches the name of
+// the file, and one with slashes its trailing directories as well.
+func matchPath(pattern, path string) bool {
+	return matchSegments(strings.Split("**/"+filepath.ToSlash(pattern), "/"),
+		strings.Split(filepath.ToSlash(path), "/"), false, true)
+}
+
+// checkPattern returns an error if a segment of a pattern is malformed.
+func checkPattern(pattern []string) error {
+	for _, segment := range pattern {
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchSegments tells whether the segments of pattern match those of name.
+// With partial, it also tells whether name could be the start of a path
+// that matches, so that glob knows which directories to look into. Unless
+// dotfiles is set, wildcards do not match names starting with a dot.
+func matchSegments(pattern, name []string, partial, dotfiles bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:], partial, dotfiles) {
+					return true
+				}
+				if i < len(name) && !dotfiles && strings.HasPrefix(name[i], ".") {
+					return false
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return partial
+		}
+		if !dotfiles && strings.HasPrefix(name[0], ".") &&
+			!strings.HasPrefix(pattern[0], ".") {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
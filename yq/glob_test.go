@@ -0,0 +1,196 @@
+package yq
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files at the given slash-separated paths under a
+// new temporary directory, and returns the directory.
+func writeTree(t *testing.T, paths ...string) string {
+	dir, err := ioutil.TempDir("", "yq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("a: 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGlob(t *testing.T) {
+	dir := writeTree(t, "a.yaml", "b.json", "k8s/c.yaml", "k8s/base/d.yaml",
+		"k8s/.git/e.yaml", ".f.yaml")
+	defer os.RemoveAll(dir)
+
+	type testCase struct {
+		testDescription string
+		pattern         string
+		expected        []string
+	}
+	testcases := []testCase{
+		{
+			"Matches the names of a directory",
+			"*.yaml",
+			[]string{"a.yaml"},
+		},
+		{
+			"Matches through several directories",
+			"k8s/*/*.yaml",
+			[]string{"k8s/base/d.yaml"},
+		},
+		{
+			"Matches any number of directories with **",
+			"**/*.yaml",
+			[]string{"a.yaml", "k8s/base/d.yaml", "k8s/c.yaml"},
+		},
+		{
+			"Matches names starting with a dot explicitly",
+			"k8s/.*/*.yaml",
+			[]string{"k8s/.git/e.yaml"},
+		},
+		{
+			"Matches nothing under missing directories",
+			"missing/*.yaml",
+			nil,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			matches, err := glob(filepath.Join(dir, tCase.pattern))
+			if err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			var actual []string
+			for _, match := range matches {
+				rel, _ := filepath.Rel(dir, match)
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(tCase.expected, actual) {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	type testCase struct {
+		pattern  string
+		path     string
+		expected bool
+	}
+	testcases := []testCase{
+		{"*.yaml", "k8s/a.yaml", true},
+		{"*.yaml", "k8s/.a.yaml", true},
+		{"*.yaml", "a.json", false},
+		{"base/*.yaml", "k8s/base/a.yaml", true},
+		{"base/*.yaml", "k8s/base/x/a.yaml", false},
+		{"base/**", "k8s/base/x/a.yaml", true},
+		{"k8s", "k8s", true},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.pattern+" "+tCase.path, func(t *testing.T) {
+			if actual := matchPath(tCase.pattern, tCase.path); tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAddInput(t *testing.T) {
+	dir := writeTree(t, "k8s/b.yaml", "k8s/a.yml", "k8s/base/c.json",
+		"k8s/base/d.toml", "k8s/README.md", "k8s/test/e.yaml")
+	defer os.RemoveAll(dir)
+
+	type testCase struct {
+		testDescription string
+		args            []string
+		expected        []string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Requires --recursive to read directories",
+			[]string{"k8s"},
+			nil,
+			true,
+		},
+		{
+			"Reads the files of formats yq reads under directories, in order",
+			[]string{"--recursive", "k8s"},
+			[]string{"k8s/a.yml", "k8s/b.yaml", "k8s/base/c.json",
+				"k8s/base/d.toml", "k8s/test/e.yaml"},
+			false,
+		},
+		{
+			"Reads only the files of the input format when it is set",
+			[]string{"--recursive", "--input-format", "json", "k8s"},
+			[]string{"k8s/base/c.json"},
+			false,
+		},
+		{
+			"Filters the files with --include and --exclude",
+			[]string{"--recursive", "--include", "*.yaml", "--include", "*.md",
+				"--exclude", "test", "k8s"},
+			[]string{"k8s/README.md", "k8s/b.yaml"},
+			false,
+		},
+		{
+			"Reads the files matching patterns once",
+			[]string{"k8s/**/*.yaml", "k8s/*.y*ml"},
+			[]string{"k8s/b.yaml", "k8s/test/e.yaml", "k8s/a.yml"},
+			false,
+		},
+		{
+			"Fails on patterns matching no file",
+			[]string{"k8s/*.xml"},
+			nil,
+			true,
+		},
+		{
+			"Reads files given explicitly whatever their name",
+			[]string{"--exclude", "*.md", "k8s/README.md"},
+			[]string{"k8s/README.md"},
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			osArgs := []string{"yq", "."}
+			for _, arg := range tCase.args {
+				if strings.HasPrefix(arg, "k8s") {
+					arg = filepath.Join(dir, arg)
+				}
+				osArgs = append(osArgs, arg)
+			}
+
+			var y yq
+			err := y.compileJqCmd(osArgs, ioutil.Discard)
+			if !tCase.shouldError && err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			if tCase.shouldError && err == nil {
+				t.Fatal("Expected compileJqCmd to return an error and it did not")
+			}
+
+			var actual []string
+			for _, file := range y.files {
+				rel, _ := filepath.Rel(dir, file)
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			if !tCase.shouldError && !reflect.DeepEqual(tCase.expected, actual) {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package yq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// jsonDecoder decodes a stream of JSON texts, each being a document, like
+// jq reads them. Object keys keep their order and numbers the way they are
+// written.
+type jsonDecoder struct {
+	dec   *json.Decoder
+	lines *lineCounter
+}
+
+func newJSONDecoder(r io.Reader) *jsonDecoder {
+	lines := &lineCounter{r: r}
+	dec := json.NewDecoder(lines)
+	dec.UseNumber()
+	return &jsonDecoder{dec: dec, lines: lines}
+}
+
+// Decode decodes the next JSON text into v, a *yaml.Node.
+func (d *jsonDecoder) Decode(v interface{}) error {
+	// More is false at the end of the input, and before what cannot start
+	// a JSON text, for which Token returns the error.
+	if !d.dec.More() {
+		if _, err := d.dec.Token(); err != io.EOF {
+			return d.error(err)
+		}
+		return io.EOF
+	}
+
+	node, err := decodeJSONNode(d.dec)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return d.error(err)
+	}
+	*v.(*yaml.Node) = yaml.Node{Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{node}}
+	return nil
+}
+
+// error returns err, an error decoding JSON, as a DecodeError located at
+// the line and column of its offset.
+func (d *jsonDecoder) error(err error) error {
+	e := &DecodeError{Format: "json", Message: err.Error()}
+	offset := d.dec.InputOffset()
+	if err, ok := err.(*json.SyntaxError); ok {
+		// The offset is past the invalid character.
+		offset = err.Offset - 1
+	}
+	if err == io.ErrUnexpectedEOF {
+		e.Message = "unexpected end of JSON input"
+	}
+	if offset >= 0 {
+		e.Line, e.Column = d.lines.position(offset)
+	}
+	return e
+}
+
+// lineCounter records where the lines of the data read through it start,
+// to locate errors from their offset.
+type lineCounter struct {
+	r      io.Reader
+	read   int64
+	starts []int64
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.starts = append(c.starts, c.read+int64(i)+1)
+		}
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+// position returns the line and column of offset, both starting at 1.
+func (c *lineCounter) position(offset int64) (int, int) {
+	line := sort.Search(len(c.starts), func(i int) bool {
+		return c.starts[i] > offset
+	})
+	start := int64(0)
+	if line > 0 {
+		start = c.starts[line-1]
+	}
+	return line + 1, int(offset-start) + 1
+}
+
+// jsonEncoder writes documents as JSON texts, indented like jq indents
+// them.
+type jsonEncoder struct {
+	w      io.Writer
+	format outputFormat
+	buf    bytes.Buffer
+	out    bytes.Buffer
+}
+
+// Encode writes doc, a DocumentNode.
+func (e *jsonEncoder) Encode(doc *yaml.Node) error {
+	e.buf.Reset()
+	if err := writeJSON(&e.buf, doc); err != nil {
+		return err
+	}
+
+	indent := e.format.indent
+	if indent == 0 {
+		indent = 2
+	}
+	e.out.Reset()
+	if err := json.Indent(&e.out, e.buf.Bytes(), "",
+		strings.Repeat(" ", indent)); err != nil {
+		return errors.New("json: " + err.Error())
+	}
+	e.out.WriteByte('\n')
+	_, err := e.w.Write(e.out.Bytes())
+	return err
+}
@@ -0,0 +1,75 @@
+package yq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJSONDecoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		json            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Reads a stream of JSON texts keeping the order of keys",
+			"{\"zulu\": 1, \"alpha\": [true, null]}\n{\"b\": \"x\"} 3",
+			`{"zulu":1,"alpha":[true,null]}
+{"b":"x"}
+3`,
+			false,
+		},
+		{
+			"Errors on invalid JSON with its location",
+			"{\"a\": 1}\n{\"a\" 2}",
+			"",
+			true,
+		},
+		{
+			"Errors on numbers out of the range of floats",
+			`{"a": 1e400}`,
+			"",
+			true,
+		},
+		{
+			"Errors on truncated JSON",
+			`{"a": [1`,
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			jt := jqPipe{documentOptions: documentOptions{inputFormat: "json"}}
+			err := jt.toJSON(strings.NewReader(tCase.json), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toJSON to return an error and it did not")
+			}
+		})
+	}
+}
+
+func TestJSONDecoderErrorLocation(t *testing.T) {
+	var b bytes.Buffer
+	jt := jqPipe{documentOptions: documentOptions{inputFormat: "json"}}
+	err := jt.toJSON(strings.NewReader("{\"a\": 1}\n{\"a\" 2}"), &b)
+	expected := "json: document 2, line 2, column 6: invalid character '2' " +
+		"after object key"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected '%v' got '%v'", expected, err)
+	}
+}
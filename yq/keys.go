@@ -0,0 +1,100 @@
+package yq
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// keyPolicy returns doc with the mapping keys that are not strings, which
+// JSON does not have, handled as policy says: stringify replaces them with
+// their canonical form, the string a key such as 1, true or null stands for
+// in JSON, or the compact JSON text of a collection, skip drops them along
+// with their value, and error leaves them for mappingPairs to reject. The
+// mappings of doc are changed in place.
+func keyPolicy(doc *yaml.Node, policy string) (*yaml.Node, error) {
+	if policy != "stringify" && policy != "skip" {
+		return doc, nil
+	}
+	return doc, rekey(doc, policy, map[*yaml.Node]bool{})
+}
+
+// rekey applies policy to the mappings of node and its children, which
+// seen records so that anchored nodes are handled once.
+func rekey(node *yaml.Node, policy string, seen map[*yaml.Node]bool) error {
+	if seen[node] {
+		return nil
+	}
+	seen[node] = true
+	if node.Kind == yaml.AliasNode {
+		return rekey(node.Alias, policy, seen)
+	}
+	for _, child := range node.Content {
+		if err := rekey(child, policy, seen); err != nil {
+			return err
+		}
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if k := resolve(key); !isMerge(k) && !isStringKey(k) {
+			if policy == "skip" {
+				continue
+			}
+			name, err := canonicalKey(k)
+			if err != nil {
+				return err
+			}
+			key = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name,
+				Line: key.Line, Column: key.Column, HeadComment: key.HeadComment,
+				LineComment: key.LineComment, FootComment: key.FootComment}
+		}
+		content = append(content, key, value)
+	}
+	node.Content = content
+	return nil
+}
+
+func isStringKey(key *yaml.Node) bool {
+	_, err := keyString(key)
+	return err == nil
+}
+
+// canonicalKey returns the canonical form of key as a string.
+func canonicalKey(key *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, key); err != nil {
+		return "", err
+	}
+	var s string
+	if buf.Bytes()[0] == '"' && json.Unmarshal(buf.Bytes(), &s) == nil {
+		return s, nil
+	}
+	return buf.String(), nil
+}
+
+// intKey matches the keys --restore-int-keys writes as integers.
+var intKey = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+
+// restoreIntKeys turns the string keys of the mappings of node that are
+// decimal integers, as stringify leaves integer keys, back into integers.
+func restoreIntKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind == yaml.ScalarNode && key.ShortTag() == "!!str" &&
+				intKey.MatchString(key.Value) {
+				key.Tag, key.Style = "!!int", 0
+			}
+		}
+	}
+	for _, child := range node.Content {
+		restoreIntKeys(child)
+	}
+}
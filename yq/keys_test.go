@@ -0,0 +1,126 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		policy          string
+		yaml            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Rejects keys that are not strings by default",
+			"",
+			"1: a",
+			"",
+			true,
+		},
+		{
+			"Rejects keys that are not strings",
+			"error",
+			"a: {true: b}",
+			"",
+			true,
+		},
+		{
+			"Turns scalar keys into their canonical string",
+			"stringify",
+			"1: a\n0x10: b\ntrue: c\n~: d\n2.50: e\n2001-12-14: f\n",
+			`{"1":"a","16":"b","true":"c","null":"d","2.5":"e","2001-12-14T00:00:00Z":"f"}`,
+			false,
+		},
+		{
+			"Turns complex keys into compact JSON",
+			"stringify",
+			"? [a, {1: b}]\n: c\n",
+			`{"[\"a\",{\"1\":\"b\"}]":"c"}`,
+			false,
+		},
+		{
+			"Turns the keys of merged mappings into strings",
+			"stringify",
+			"x: &x {1: a}\ny: {<<: *x, 2: b}\n",
+			`{"x":{"1":"a"},"y":{"1":"a","2":"b"}}`,
+			false,
+		},
+		{
+			"Rejects keys whose string is already defined",
+			"stringify",
+			"1: a\n\"1\": b\n",
+			"",
+			true,
+		},
+		{
+			"Skips keys that are not strings",
+			"skip",
+			"1: a\nb: {true: c, d: e}\n",
+			`{"b":{"d":"e"}}`,
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			kt := jqPipe{documentOptions: documentOptions{keyPolicy: tCase.policy}}
+			err := kt.toJSON(strings.NewReader(tCase.yaml), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toJSON to return an error and it did not")
+			}
+		})
+	}
+}
+
+func TestRestoreIntKeys(t *testing.T) {
+	input := "1: a # one\n\"true\": b\nc:\n  -2: d\n  \"03\": e\n"
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Writes integer keys back as integers",
+			[]string{"yq", "-y", "--key-policy", "stringify", "--restore-int-keys", "."},
+			input,
+		},
+		{
+			"Writes integer keys as strings by default",
+			[]string{"yq", "-y", "--key-policy", "stringify", "."},
+			"\"1\": a # one\n\"true\": b\nc:\n  \"-2\": d\n  \"03\": e\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			if err := y.engine.run(nil, strings.NewReader(input), &b); err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+			if tCase.expected != b.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package yq
+
+import (
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// mergeKeys returns doc with its merge keys (<<) handled as mode says:
+// expand leaves them to be merged into their mapping, preserve turns them
+// into plain "<<" keys, which the YAML output writes back as merge keys,
+// and reject fails on the first one.
+func mergeKeys(doc *yaml.Node, mode string) (*yaml.Node, error) {
+	switch mode {
+	case "preserve":
+		return preserveMerges(doc, map[*yaml.Node]*yaml.Node{}), nil
+	case "reject":
+		return doc, rejectMerges(doc, map[*yaml.Node]bool{})
+	}
+	return doc, nil
+}
+
+// preserveMerges returns a copy of node where merge keys are strings.
+// Aliases refer to the copies of their anchored nodes, recorded in copies.
+func preserveMerges(node *yaml.Node, copies map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if c, ok := copies[node]; ok {
+		return c
+	}
+	c := *node
+	copies[node] = &c
+	if node.Kind == yaml.AliasNode {
+		c.Alias = preserveMerges(node.Alias, copies)
+		return &c
+	}
+
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 && isMerge(child) {
+			key := *child
+			key.Tag = "!!str"
+			c.Content[i] = &key
+			continue
+		}
+		c.Content[i] = preserveMerges(child, copies)
+	}
+	return &c
+}
+
+// rejectMerges returns an error located at the first merge key of node.
+func rejectMerges(node *yaml.Node, seen map[*yaml.Node]bool) error {
+	if seen[node] {
+		return nil
+	}
+	seen[node] = true
+	if node.Kind == yaml.AliasNode {
+		return rejectMerges(node.Alias, seen)
+	}
+
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 && isMerge(child) {
+			return nodeError(child, "merge keys are not allowed with "+
+				"--merge-keys=reject")
+		}
+		if err := rejectMerges(child, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package yq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMergeKeys(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		mode            string
+		yaml            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Expands merge keys by default",
+			"",
+			"base: &b {a: 1, b: 2}\njob: {<<: *b, c: 3}",
+			`{"base":{"a":1,"b":2},"job":{"a":1,"b":2,"c":3}}`,
+			false,
+		},
+		{
+			"Keys of the mapping override merged keys wherever they are",
+			"expand",
+			"base: &b {a: 1, b: 2}\njob: {b: 3, <<: *b, a: 4}",
+			`{"base":{"a":1,"b":2},"job":{"b":3,"a":4}}`,
+			false,
+		},
+		{
+			"Earlier mappings of a list of merges override later ones",
+			"expand",
+			"x: &x {a: 1}\ny: &y {a: 2, b: 2}\njob: {<<: [*x, *y], c: 3}",
+			`{"x":{"a":1},"y":{"a":2,"b":2},"job":{"a":1,"b":2,"c":3}}`,
+			false,
+		},
+		{
+			"Merges nested merge keys",
+			"expand",
+			"x: &x {a: 1}\ny: &y {<<: *x, b: 2}\njob: {<<: *y}",
+			`{"x":{"a":1},"y":{"a":1,"b":2},"job":{"a":1,"b":2}}`,
+			false,
+		},
+		{
+			"Preserves merge keys as plain keys",
+			"preserve",
+			"x: &x {a: 1}\ny: &y {<<: *x, b: 2}\njob: {<<: [*x, *y], a: 3}",
+			`{"x":{"a":1},"y":{"<<":{"a":1},"b":2},"job":{"<<":[{"a":1},` +
+				`{"<<":{"a":1},"b":2}],"a":3}}`,
+			false,
+		},
+		{
+			"Rejects merge keys",
+			"reject",
+			"x: &x {a: 1}\njob: {<<: *x}",
+			"",
+			true,
+		},
+		{
+			"Rejects merge keys reached through aliases",
+			"reject",
+			"x: &x {<<: {a: 1}}\njob: [*x]",
+			"",
+			true,
+		},
+		{
+			"Accepts documents without merge keys when rejecting",
+			"reject",
+			"x: &x {a: 1}\njob: [*x]",
+			`{"x":{"a":1},"job":[{"a":1}]}`,
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			mt := jqPipe{documentOptions: documentOptions{mergeKeys: tCase.mode}}
+			err := mt.toJSON(strings.NewReader(tCase.yaml), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toJSON to return an error and it did not")
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package yq
+
+import (
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// decimalInt matches the decimal integers YAML resolves as floats when they
+// do not fit in 64 bits.
+var decimalInt = regexp.MustCompile(`^[-+]?[0-9]+$`)
+
+// bigInt returns the value of a float node spelled as a decimal integer,
+// keeping the digits a float64 would round away.
+func bigInt(node *yaml.Node) (*big.Int, bool) {
+	if !decimalInt.MatchString(node.Value) {
+		return nil, false
+	}
+	return new(big.Int).SetString(strings.TrimPrefix(node.Value, "+"), 10)
+}
+
+// outOfRange reports whether node is a float too large for a float64, which
+// YAML only resolves as a float when it is tagged as one, as the numbers
+// read from JSON are.
+func outOfRange(node *yaml.Node) bool {
+	if node.ShortTag() != "!!float" {
+		return false
+	}
+	f, err := strconv.ParseFloat(strings.Replace(node.Value, "_", "", -1), 64)
+	return err != nil && math.IsInf(f, 0)
+}
+
+// decimalFloat matches the floats YAML spells in decimal.
+var decimalFloat = regexp.MustCompile(`^([-+]?)([0-9]*)(?:\.([0-9]*))?(?:[eE]([-+]?[0-9]+))?$`)
+
+// numberValue returns the exact value of a number node, as the digits of
+// its significand, without leading or trailing zeros, and its exponent.
+// Numbers are compared this way rather than as float64, which would take
+// numbers differing beyond its precision for the same.
+func numberValue(node *yaml.Node) (string, bool) {
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+	default:
+		return "", false
+	}
+	v, err := scalarValue(node)
+	if err != nil {
+		return "", false
+	}
+	var literal string
+	switch v := v.(type) {
+	case int:
+		literal = strconv.Itoa(v)
+	case *big.Int:
+		literal = v.String()
+	case float64:
+		literal = strings.Replace(node.Value, "_", "", -1)
+		if !decimalFloat.MatchString(literal) {
+			literal = strconv.FormatFloat(v, 'e', -1, 64)
+		}
+	default:
+		return "", false
+	}
+
+	m := decimalFloat.FindStringSubmatch(literal)
+	exponent := 0
+	if m[4] != "" {
+		if exponent, err = strconv.Atoi(m[4]); err != nil {
+			return "", false
+		}
+	}
+	digits := strings.TrimLeft(m[2]+m[3], "0")
+	exponent -= len(m[3])
+	for strings.HasSuffix(digits, "0") {
+		digits = digits[:len(digits)-1]
+		exponent++
+	}
+	if digits == "" {
+		return "0", true
+	}
+	sign := ""
+	if m[1] == "-" {
+		sign = "-"
+	}
+	return sign + digits + "e" + strconv.Itoa(exponent), true
+}
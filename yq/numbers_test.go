@@ -0,0 +1,75 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestNumbers(t *testing.T) {
+	input := "a: 0x1F # hex\nb: 0o17\nc: 1.0\nd: 1e3\n" +
+		"e: 12345678901234567890123\nf: -98765432109876543210\ng: 5\n"
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Keeps big integers in JSON",
+			[]string{"yq", "-c", "."},
+			`{"a":31,"b":15,"c":1,"d":1000,"e":12345678901234567890123,` +
+				`"f":-98765432109876543210,"g":5}` + "\n",
+		},
+		{
+			"Keeps the literals of numbers in YAML",
+			[]string{"yq", "-y", "."},
+			input,
+		},
+		{
+			"Writes the numbers the filter modified as jq does",
+			[]string{"yq", "-y", ".a += 1 | .c *= 2 | .g = 31"},
+			"a: 32 # hex\nb: 0o17\nc: 2\nd: 1e3\n" +
+				"e: 12345678901234567890123\nf: -98765432109876543210\ng: 31\n",
+		},
+		{
+			"Writes big integers the filter modified beyond float64 precision",
+			[]string{"yq", "-y", ".e = 12345678901234567890124 | .f += 1 | .b = 9007199254740993"},
+			"a: 0x1F # hex\nb: 9007199254740993\nc: 1.0\nd: 1e3\n" +
+				"e: 12345678901234567890124\nf: -98765432109876543209\ng: 5\n",
+		},
+		{
+			"Keeps the literals of numbers set to the same value",
+			[]string{"yq", "-y", ".c = 1 | .b = 15"},
+			input,
+		},
+		{
+			"Does not give literals to numbers that moved",
+			[]string{"yq", "-y", "{a: .b, b: .a}"},
+			"a: 15 # hex\nb: 31\n",
+		},
+		{
+			"Does not give literals to strings",
+			[]string{"yq", "-y", ".a |= tostring"},
+			"a: \"31\" # hex\nb: 0o17\nc: 1.0\nd: 1e3\n" +
+				"e: 12345678901234567890123\nf: -98765432109876543210\ng: 5\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			if err := y.engine.run(nil, strings.NewReader(input), &b); err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+			if tCase.expected != b.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, b.String())
+			}
+		})
+	}
+}
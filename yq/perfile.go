@@ -0,0 +1,121 @@
+package yq
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// fileRun is the outcome of filtering a single file: its output, the
+// errors jq reported on it and the error filterFile returned.
+type fileRun struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	err    error
+	done   chan struct{}
+}
+
+// filterFiles filters every file separately, --jobs of them at once, and
+// calls report with the outcome of each one in the order of the files, as
+// soon as it is known. No file is started after report returns false.
+func (yq *yq) filterFiles(inPlace bool, report func(int, *fileRun) bool) error {
+	jobs := yq.jobs
+	if jobs > len(yq.files) {
+		jobs = len(yq.files)
+	}
+	// Running the filter with an engine is not safe for concurrent use, so
+	// every job has its own.
+	engines := make([]*engine, jobs)
+	if yq.engine != nil {
+		engines[0] = yq.engine
+		for i := 1; i < jobs; i++ {
+			e, err := newEngine(yq, yq.filter)
+			if err != nil {
+				return err
+			}
+			engines[i] = e
+		}
+	}
+
+	runs := make([]*fileRun, len(yq.files))
+	for i := range runs {
+		runs[i] = &fileRun{done: make(chan struct{})}
+	}
+	next := make(chan int)
+	stop := make(chan struct{})
+	go func() {
+		defer close(next)
+		for i := range runs {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, e := range engines {
+		wg.Add(1)
+		go func(e *engine) {
+			defer wg.Done()
+			for i := range next {
+				r := runs[i]
+				r.err = yq.filterFile(e, yq.files[i], inPlace, &r.stdout, &r.stderr)
+				close(r.done)
+			}
+		}(e)
+	}
+	defer wg.Wait()
+	defer close(stop)
+
+	for i, r := range runs {
+		<-r.done
+		if !report(i, r) {
+			break
+		}
+	}
+	return nil
+}
+
+// runPerFile runs the filter separately over each file, the way it would
+// run over the file alone, and writes the output of each file and the
+// errors jq reports on it with its name at the start of every line, as grep
+// does. It exits with the highest status of any file.
+func (yq *yq) runPerFile(stdout, stderr io.Writer) error {
+	out := bufio.NewWriter(stdout)
+	status := 0
+	err := yq.filterFiles(false, func(i int, r *fileRun) bool {
+		label := yq.files[i] + ":"
+		writeLabeled(out, label, r.stdout.Bytes())
+		out.Flush()
+		writeLabeled(stderr, label+" ", r.stderr.Bytes())
+		if r.err != nil {
+			if s := yq.report(stderr, r.err); s > status {
+				status = s
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return &exitError{status: status}
+	}
+	return nil
+}
+
+// writeLabeled writes data to w with label at the start of every line.
+func writeLabeled(w io.Writer, label string, data []byte) {
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		io.WriteString(w, label)
+		w.Write(data[:n])
+		data = data[n:]
+	}
+}
@@ -0,0 +1,125 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPerFile(t *testing.T) {
+	engines := map[string][]string{"embedded jq": nil}
+	if _, err := exec.LookPath("jq"); err == nil {
+		engines["jq binary"] = []string{"--jq-binary", "jq"}
+	}
+
+	dir, err := ioutil.TempDir("", "yq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.yaml": "kind: A\n---\nkind: B\n",
+		"b.yaml": "kind: C\n",
+		"c.yaml": "kind: [\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		files           []string
+		expected        string
+		status          int
+	}
+	testcases := []testCase{
+		{
+			"Labels the output of every file",
+			[]string{"yq", "-c", "--per-file", ".kind"},
+			[]string{"a.yaml", "b.yaml"},
+			"a.yaml:\"A\"\na.yaml:\"B\"\nb.yaml:\"C\"",
+			0,
+		},
+		{
+			"Slurps every file on its own",
+			[]string{"yq", "-c", "--per-file", "-s", "map(.kind)"},
+			[]string{"a.yaml", "b.yaml"},
+			"a.yaml:[\"A\",\"B\"]\nb.yaml:[\"C\"]",
+			0,
+		},
+		{
+			"Labels every line of YAML output",
+			[]string{"yq", "-y", "--per-file", "."},
+			[]string{"a.yaml", "b.yaml"},
+			"a.yaml:kind: A\na.yaml:---\na.yaml:kind: B\nb.yaml:kind: C",
+			0,
+		},
+		{
+			"Keeps the order of the files with several jobs",
+			[]string{"yq", "-r", "--per-file", "--jobs", "3", ".kind"},
+			[]string{"b.yaml", "a.yaml", "b.yaml", "a.yaml"},
+			"b.yaml:C\na.yaml:A\na.yaml:B\nb.yaml:C\na.yaml:A\na.yaml:B",
+			0,
+		},
+		{
+			"Goes on after a file fails to decode",
+			[]string{"yq", "-r", "--per-file", "--jobs", "2", ".kind"},
+			[]string{"c.yaml", "b.yaml"},
+			"b.yaml:C",
+			1,
+		},
+		{
+			"Exits with the highest status of any file",
+			[]string{"yq", "-e", "--per-file", `.kind == "C"`},
+			[]string{"b.yaml", "a.yaml"},
+			"b.yaml:true\na.yaml:false\na.yaml:false",
+			1,
+		},
+	}
+	for _, tCase := range testcases {
+		for engine, engineArgs := range engines {
+			t.Run(tCase.testDescription+" with "+engine, func(t *testing.T) {
+				osArgs := append(append([]string{}, tCase.osArgs...), engineArgs...)
+				for _, name := range tCase.files {
+					osArgs = append(osArgs, filepath.Join(dir, name))
+				}
+
+				var y yq
+				if err := y.compileJqCmd(osArgs, ioutil.Discard); err != nil {
+					t.Fatal("Did not expect an error got: ", err)
+				}
+				var stdout bytes.Buffer
+				err := y.runPerFile(&stdout, ioutil.Discard)
+
+				status := 0
+				if e, ok := err.(*exitError); ok {
+					status = e.status
+				} else if err != nil {
+					t.Fatal("Did not expect an error got: ", err)
+				}
+				if tCase.status != status {
+					t.Errorf("Expected status %d got %d", tCase.status, status)
+				}
+				actual := strings.Trim(strings.Replace(stdout.String(),
+					dir+string(filepath.Separator), "", -1), "\r\n")
+				if tCase.expected != actual {
+					t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+				}
+			})
+		}
+	}
+}
+
+func TestPerFileRequiresFiles(t *testing.T) {
+	var y yq
+	if err := y.compileJqCmd([]string{"yq", "--per-file", "."}, ioutil.Discard); err == nil {
+		t.Error("Expected compileJqCmd to return an error and it did not")
+	}
+}
@@ -0,0 +1,200 @@
+package yq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// jq 1.6 default colors, followed by the colors of YAML anchors, aliases and
+// tags, and of YAML comments.
+var (
+	nullColor   = "1;30"
+	falseColor  = "0;39"
+	trueColor   = "0;39"
+	numberColor = "0;39"
+	stringColor = "0;32"
+	arrayColor  = "1;39"
+	objectColor = "1;39"
+	fieldColor  = "34;1"
+
+	anchorColor  = "0;33"
+	commentColor = "0;90"
+)
+
+// jsonPrinter writes nodes built by valueToNode as JSON, formatted the way
+// the jq binary formats its output.
+type jsonPrinter struct {
+	indent string
+	ascii  bool
+	color  bool
+}
+
+func newJSONPrinter(flags jqFlags, color bool) *jsonPrinter {
+	p := &jsonPrinter{
+		indent: strings.Repeat(" ", flags.indent),
+		ascii:  flags.ascii,
+		color:  color,
+	}
+	if flags.compact {
+		p.indent = ""
+	} else if flags.tab {
+		p.indent = "\t"
+	}
+	return p
+}
+
+func (p *jsonPrinter) print(buf *bytes.Buffer, node *yaml.Node) {
+	p.value(buf, node, 0)
+}
+
+func (p *jsonPrinter) value(buf *bytes.Buffer, node *yaml.Node, depth int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		p.start(buf, objectColor)
+		buf.WriteByte('{')
+		for i := 0; i < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			p.newline(buf, depth+1)
+			p.end(buf)
+			p.start(buf, fieldColor)
+			writeJSONString(buf, node.Content[i].Value, p.ascii)
+			p.end(buf)
+			p.start(buf, objectColor)
+			buf.WriteByte(':')
+			if p.indent != "" {
+				buf.WriteByte(' ')
+			}
+			p.end(buf)
+			p.value(buf, node.Content[i+1], depth+1)
+			p.start(buf, objectColor)
+		}
+		if len(node.Content) > 0 {
+			p.newline(buf, depth)
+			p.start(buf, objectColor)
+		}
+		buf.WriteByte('}')
+		p.end(buf)
+	case yaml.SequenceNode:
+		p.start(buf, arrayColor)
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			p.newline(buf, depth+1)
+			p.value(buf, item, depth+1)
+			p.start(buf, arrayColor)
+		}
+		if len(node.Content) > 0 {
+			p.newline(buf, depth)
+			p.start(buf, arrayColor)
+		}
+		buf.WriteByte(']')
+		p.end(buf)
+	default:
+		switch {
+		case node.Tag == "!!str":
+			p.start(buf, stringColor)
+			writeJSONString(buf, node.Value, p.ascii)
+		case node.Value == "null":
+			p.start(buf, nullColor)
+			buf.WriteString(node.Value)
+		case node.Value == "true":
+			p.start(buf, trueColor)
+			buf.WriteString(node.Value)
+		case node.Value == "false":
+			p.start(buf, falseColor)
+			buf.WriteString(node.Value)
+		default:
+			p.start(buf, numberColor)
+			buf.WriteString(node.Value)
+		}
+		p.end(buf)
+	}
+}
+
+func (p *jsonPrinter) newline(buf *bytes.Buffer, depth int) {
+	if p.indent == "" {
+		return
+	}
+	buf.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		buf.WriteString(p.indent)
+	}
+}
+
+func (p *jsonPrinter) start(buf *bytes.Buffer, color string) {
+	if p.color {
+		buf.WriteString("\x1b[" + color + "m")
+	}
+}
+
+func (p *jsonPrinter) end(buf *bytes.Buffer) {
+	if p.color {
+		buf.WriteString("\x1b[0m")
+	}
+}
+
+// writeJSONString writes s as a JSON string, escaping the same characters jq
+// does, and with ascii every non-ASCII character.
+func writeJSONString(buf *bytes.Buffer, s string, ascii bool) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			switch {
+			case ascii:
+				r1, r2 := utf16.EncodeRune(r)
+				if r1 == unicode.ReplacementChar {
+					r1 = r
+				} else {
+					fmt.Fprintf(buf, `\u%04x`, r1)
+					r1 = r2
+				}
+				fmt.Fprintf(buf, `\u%04x`, r1)
+			case r == utf8.RuneError && size == 1:
+				buf.WriteString(`�`)
+			default:
+				buf.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		}
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < ' ' || c == 0x7f {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,203 @@
+package yq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// nodeProperties selects the properties of YAML nodes, their tags and
+// their anchors, that jq sees rather than yq resolving them. A node with
+// such properties is an object holding its "$tag" and "$anchor" next to
+// its "$value", and an alias is an object holding the anchor it refers to
+// as "$alias". The YAML output turns these objects back into tagged and
+// anchored nodes and aliases.
+type nodeProperties struct {
+	tags    bool
+	anchors bool
+}
+
+func (p nodeProperties) enabled() bool {
+	return p.tags || p.anchors
+}
+
+// wrap returns a copy of node where the properties are objects.
+func (p nodeProperties) wrap(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		if !p.anchors {
+			return p.wrap(node.Alias)
+		}
+		alias := mappingNode(node, pair{"$alias", stringNode(node.Value)})
+		alias.HeadComment = node.HeadComment
+		alias.LineComment = node.LineComment
+		alias.FootComment = node.FootComment
+		return alias
+	}
+
+	value := *node
+	value.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		switch {
+		case node.Kind == yaml.MappingNode && i%2 == 0:
+			value.Content[i] = child
+		case node.Kind == yaml.MappingNode && isMerge(resolve(node.Content[i-1])):
+			// The sources of merge keys are still merged.
+			value.Content[i] = child
+		default:
+			value.Content[i] = p.wrap(child)
+		}
+	}
+
+	var properties []pair
+	if tag := customTag(node); p.tags && tag != "" {
+		properties = append(properties, pair{"$tag", stringNode(tag)})
+		value.Style &^= yaml.TaggedStyle
+		value.Tag = ""
+		switch node.Kind {
+		case yaml.MappingNode:
+			value.Tag = "!!map"
+		case yaml.SequenceNode:
+			value.Tag = "!!seq"
+		}
+	}
+	if p.anchors && node.Anchor != "" {
+		properties = append(properties, pair{"$anchor", stringNode(node.Anchor)})
+	}
+	value.Anchor = ""
+	if len(properties) == 0 || node.Kind == yaml.DocumentNode {
+		return &value
+	}
+
+	wrapper := mappingNode(node, append(properties, pair{"$value", &value})...)
+	moveComments(wrapper, &value)
+	return wrapper
+}
+
+// unwrap turns the objects standing for properties in node back into
+// properties, in place, and returns the resulting node.
+func (p nodeProperties) unwrap(node *yaml.Node) *yaml.Node {
+	for i, child := range node.Content {
+		node.Content[i] = p.unwrap(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return node
+	}
+
+	properties := map[string]*yaml.Node{}
+	for i := 0; i < len(node.Content); i += 2 {
+		properties[node.Content[i].Value] = node.Content[i+1]
+	}
+	isString := func(key string) bool {
+		value, ok := properties[key]
+		return ok && value.Kind == yaml.ScalarNode && value.ShortTag() == "!!str"
+	}
+
+	if p.anchors && len(properties) == 1 && isString("$alias") {
+		alias := &yaml.Node{Kind: yaml.AliasNode, Value: properties["$alias"].Value}
+		moveComments(alias, node)
+		return alias
+	}
+
+	value, ok := properties["$value"]
+	if !ok {
+		return node
+	}
+	n := 1
+	for _, key := range []string{"$tag", "$anchor"} {
+		if _, ok := properties[key]; !ok {
+			continue
+		}
+		enabled := key == "$tag" && p.tags || key == "$anchor" && p.anchors
+		if !enabled || !isString(key) {
+			return node
+		}
+		n++
+	}
+	if n == 1 || n != len(properties) {
+		return node
+	}
+
+	if tag, ok := properties["$tag"]; ok {
+		// A string whose tag no longer says it is one stays a string.
+		if value.Kind == yaml.ScalarNode && value.ShortTag() == "!!str" &&
+			!isPlain(value.Value) {
+			value.Style = yaml.DoubleQuotedStyle
+		}
+		value.Tag = tag.Value
+	}
+	if anchor, ok := properties["$anchor"]; ok {
+		value.Anchor = anchor.Value
+	}
+	moveComments(value, node)
+	return value
+}
+
+// checkAliases returns an error locating the first alias of doc that refers
+// to no anchor before it, such as one whose anchor the filter removed,
+// which would make the YAML written invalid.
+func checkAliases(doc *yaml.Node) error {
+	anchors := map[string]bool{}
+	var check func(node *yaml.Node, path []string) error
+	check = func(node *yaml.Node, path []string) error {
+		if node.Kind == yaml.AliasNode {
+			if !anchors[node.Value] {
+				return fmt.Errorf("yaml: %s: alias *%s refers to no anchor before it",
+					strings.Join(path, "."), node.Value)
+			}
+			return nil
+		}
+		if node.Anchor != "" {
+			anchors[node.Anchor] = true
+		}
+		for i, child := range node.Content {
+			childPath := path
+			switch node.Kind {
+			case yaml.MappingNode:
+				childPath = append(path[:len(path):len(path)], node.Content[i-i%2].Value)
+			case yaml.SequenceNode:
+				childPath = append(path[:len(path):len(path)], strconv.Itoa(i))
+			}
+			if err := check(child, childPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(doc, nil)
+}
+
+// customTag returns the tag explicitly set on node, unless it is one of
+// the tags of the values JSON has, which yq applies.
+func customTag(node *yaml.Node) string {
+	if node.Style&yaml.TaggedStyle == 0 {
+		return ""
+	}
+	switch tag := node.ShortTag(); tag {
+	case "!", "!!str", "!!int", "!!float", "!!bool", "!!null", "!!map", "!!seq":
+		return ""
+	default:
+		return tag
+	}
+}
+
+// mappingNode returns a mapping holding pairs, located at node.
+func mappingNode(node *yaml.Node, pairs ...pair) *yaml.Node {
+	m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: node.Line,
+		Column: node.Column}
+	for _, p := range pairs {
+		m.Content = append(m.Content, stringNode(p.key), p.value)
+	}
+	return m
+}
+
+// moveComments moves the comments of from onto to, unless to has its own.
+func moveComments(to, from *yaml.Node) {
+	if to.HeadComment == "" && to.LineComment == "" && to.FootComment == "" {
+		to.HeadComment = from.HeadComment
+		to.LineComment = from.LineComment
+		to.FootComment = from.FootComment
+	}
+	from.HeadComment, from.LineComment, from.FootComment = "", "", ""
+}
@@ -0,0 +1,77 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestNodeProperties(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		yaml            string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Shows custom tags as objects",
+			[]string{"yq", "-c", "--preserve-tags", "."},
+			"a: !Ref Bucket\nb: !GetAtt [x, y]\nc: !!binary aGVsbG8=\nd: !!str 1\n",
+			`{"a":{"$tag":"!Ref","$value":"Bucket"},"b":{"$tag":"!GetAtt",` +
+				`"$value":["x","y"]},"c":{"$tag":"!!binary","$value":"aGVsbG8="},"d":"1"}`,
+		},
+		{
+			"Shows anchors and aliases as objects",
+			[]string{"yq", "-c", "--preserve-anchors", "."},
+			"a: &x {b: 1}\nc: *x\n",
+			`{"a":{"$anchor":"x","$value":{"b":1}},"c":{"$alias":"x"}}`,
+		},
+		{
+			"Expands aliases without --preserve-anchors",
+			[]string{"yq", "-c", "--preserve-tags", "."},
+			"a: &x !T {b: 1}\nc: *x\n",
+			`{"a":{"$tag":"!T","$value":{"b":1}},"c":{"$tag":"!T","$value":{"b":1}}}`,
+		},
+		{
+			"Writes tags, anchors and aliases back with -y",
+			[]string{"yq", "-y", "--preserve-tags", "--preserve-anchors",
+				`.a."$value".b."$value" = "1"`},
+			"# head\na: &x !T\n  b: !Ref B # comment\nc: *x\n",
+			`# head
+a: &x !T
+  b: !Ref "1" # comment
+c: *x`,
+		},
+		{
+			"Leaves objects of other keys alone",
+			[]string{"yq", "-y", "--preserve-tags", "."},
+			`{a: {"$tag": "!T", "$value": 1, b: 2}, c: {"$anchor": x, "$value": 1}}`,
+			`a:
+  $tag: '!T'
+  $value: 1
+  b: 2
+c:
+  $anchor: x
+  $value: 1`,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			if err := y.engine.run(nil, strings.NewReader(tCase.yaml), &b); err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+			actual := strings.Trim(b.String(), "\r\n")
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package yq
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// scalarPolicy is how the YAML scalars JSON has no value for are given to
+// jq. specialFloats is what .inf, -.inf and .nan are: string, the strings
+// ".inf", "-.inf" and ".nan", null, or error, the default, rejecting them.
+// timestamps is what timestamps are: string, the default, their RFC 3339
+// text, or epoch, their seconds since the Unix epoch. binary is what
+// !!binary values are: string, the default, the bytes they decode to, or
+// base64, their base64 text, which values whose bytes are not UTF-8 keep
+// either way.
+type scalarPolicy struct {
+	specialFloats string
+	timestamps    string
+	binary        string
+}
+
+// enabled reports whether the policy maps scalars differently from the way
+// scalarValue decodes them.
+func (p scalarPolicy) enabled() bool {
+	return p.specialFloats == "string" || p.specialFloats == "null" ||
+		p.timestamps == "epoch" || p.binary == "base64"
+}
+
+// apply returns node with the scalars the policy maps replaced, leaving
+// node untouched and sharing the nodes without such scalars. The paths of
+// the values do not change, so that node is still the original of the
+// results of the filter.
+func (p scalarPolicy) apply(node *yaml.Node) *yaml.Node {
+	if !p.enabled() {
+		return node
+	}
+	return p.copy(node, map[*yaml.Node]*yaml.Node{})
+}
+
+// copy maps the scalars of node, recording in copies the node each node
+// became, so that anchored nodes are mapped once.
+func (p scalarPolicy) copy(node *yaml.Node, copies map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if c, ok := copies[node]; ok {
+		return c
+	}
+	copies[node] = node
+
+	c := node
+	switch node.Kind {
+	case yaml.AliasNode:
+		if alias := p.copy(node.Alias, copies); alias != node.Alias {
+			c = alias
+		}
+	case yaml.ScalarNode:
+		if mapped := p.scalar(node); mapped != nil {
+			c = mapped
+		}
+	default:
+		var content []*yaml.Node
+		for i, child := range node.Content {
+			if node.Kind == yaml.MappingNode && i%2 == 0 {
+				continue
+			}
+			if mapped := p.copy(child, copies); mapped != child {
+				if content == nil {
+					content = append([]*yaml.Node(nil), node.Content...)
+				}
+				content[i] = mapped
+			}
+		}
+		if content != nil {
+			value := *node
+			value.Content = content
+			c = &value
+		}
+	}
+	copies[node] = c
+	return c
+}
+
+// scalar returns the node jq sees for a special float, a timestamp or a
+// binary value, or nil for the other scalars and for special floats the
+// policy rejects.
+func (p scalarPolicy) scalar(node *yaml.Node) *yaml.Node {
+	switch node.ShortTag() {
+	case "!!float":
+		var f float64
+		if node.Decode(&f) != nil || !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return nil
+		}
+		switch p.specialFloats {
+		case "string":
+			switch {
+			case math.IsNaN(f):
+				return stringNode(".nan")
+			case f > 0:
+				return stringNode(".inf")
+			}
+			return stringNode("-.inf")
+		case "null":
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+	case "!!timestamp":
+		var t time.Time
+		if node.Decode(&t) != nil {
+			return nil
+		}
+		if p.timestamps == "epoch" {
+			return epochNode(t)
+		}
+		return stringNode(t.Format(time.RFC3339Nano))
+	case "!!binary":
+		if p.binary == "base64" {
+			return stringNode(strings.Join(strings.Fields(node.Value), ""))
+		}
+		if text, ok := binaryText(node); ok {
+			return stringNode(text)
+		}
+	}
+	return nil
+}
+
+// binaryText returns the string a !!binary node decodes to, or its base64
+// text when the bytes are not UTF-8, as JSON strings could not hold them.
+func binaryText(node *yaml.Node) (string, bool) {
+	text := strings.Join(strings.Fields(node.Value), "")
+	b, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", false
+	}
+	if !utf8.Valid(b) {
+		return text, true
+	}
+	return string(b), true
+}
+
+// epochNode returns the number of seconds from the Unix epoch to t, with
+// as many decimals as t has.
+func epochNode(t time.Time) *yaml.Node {
+	seconds, nanos := t.Unix(), t.Nanosecond()
+	if nanos == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int",
+			Value: strconv.FormatInt(seconds, 10)}
+	}
+	if seconds < 0 {
+		seconds, nanos = seconds+1, 1e9-nanos
+	}
+	value := strconv.FormatInt(seconds, 10)
+	if seconds == 0 && t.Unix() < 0 {
+		value = "-0"
+	}
+	fraction := strings.TrimRight(strconv.Itoa(1e9 + nanos)[1:], "0")
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: value + "." + fraction}
+}
+
+// keepLiterals gives the numbers, special floats, timestamps and binary
+// values of node the literal of the value they stem from in orig, found the
+// way copyComments finds it, when the filter did not modify it, that is
+// when it is still the value p gave jq. This keeps spellings JSON does not
+// have, such as 0x1F, 0o17, 1.0 or .inf, and the tags of timestamps and
+// binary values. Written as TOML, the datetimes of a TOML input also keep
+// their type.
+func keepLiterals(node, orig *yaml.Node, moved sources, p scalarPolicy, format outputFormat) {
+	if source, ok := moved[node]; ok {
+		orig = source
+	}
+	if orig == nil {
+		return
+	}
+	content := orig
+	if orig.Kind == yaml.AliasNode {
+		content = orig.Alias
+	}
+	if content == nil || node.Kind != content.Kind {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if content.Tag == tomlDatetimeTag {
+			if format.syntax == "toml" && node.ShortTag() == "!!str" &&
+				node.Value == content.Value {
+				node.Tag = tomlDatetimeTag
+			}
+			return
+		}
+		given := p.scalar(content)
+		if given == nil {
+			if _, ok := numberValue(content); !ok {
+				return
+			}
+			given = content
+		}
+		if sameScalar(node, given) {
+			node.Value, node.Tag, node.Style = content.Value, content.Tag, content.Style
+		}
+	case yaml.DocumentNode:
+		for i := 0; i < len(node.Content) && i < len(content.Content); i++ {
+			keepLiterals(node.Content[i], content.Content[i], moved, p, format)
+		}
+	case yaml.SequenceNode:
+		for i, item := range itemSources(node, content) {
+			keepLiterals(node.Content[i], item, moved, p, format)
+		}
+	case yaml.MappingNode:
+		keys := map[string]int{}
+		for i := 0; i < len(content.Content); i += 2 {
+			keys[content.Content[i].Value] = i
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			if j, ok := keys[node.Content[i].Value]; ok {
+				keepLiterals(node.Content[i+1], content.Content[j+1], moved, p, format)
+			}
+		}
+	}
+}
+
+// sameScalar reports whether a and b hold the same JSON value.
+func sameScalar(a, b *yaml.Node) bool {
+	if f, ok := numberValue(a); ok {
+		g, ok := numberValue(b)
+		return ok && f == g
+	}
+	return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+}
@@ -0,0 +1,82 @@
+package yq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestScalarPolicy(t *testing.T) {
+	input := "a: .inf # big\nb: -.Inf\nc: .nan\nd: 2001-12-14\n" +
+		"e: 2001-12-14T21:59:43.10-05:00\nf: !!binary aGVsbG8=\n" +
+		"g: 1969-12-31T23:59:59.5Z\nh: !!binary /9j/4AAQ\n"
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Rejects special floats by default",
+			[]string{"yq", "-c", "."},
+			"",
+			true,
+		},
+		{
+			"Turns special floats into strings",
+			[]string{"yq", "-c", "--special-floats", "string", "."},
+			`{"a":".inf","b":"-.inf","c":".nan","d":"2001-12-14T00:00:00Z",` +
+				`"e":"2001-12-14T21:59:43.1-05:00","f":"hello","g":"1969-12-31T23:59:59.5Z",` +
+				`"h":"/9j/4AAQ"}` + "\n",
+			false,
+		},
+		{
+			"Turns special floats into null, timestamps into seconds and keeps base64",
+			[]string{"yq", "-c", "--special-floats", "null", "--timestamps", "epoch",
+				"--binary", "base64", "."},
+			`{"a":null,"b":null,"c":null,"d":1008288000,"e":1008385183.1,` +
+				`"f":"aGVsbG8=","g":-0.5,"h":"/9j/4AAQ"}` + "\n",
+			false,
+		},
+		{
+			"Writes the scalars the filter did not modify back",
+			[]string{"yq", "-y", "--special-floats", "string", "--timestamps", "epoch", "."},
+			input,
+			false,
+		},
+		{
+			"Writes the scalars the filter modified as jq does",
+			[]string{"yq", "-y", "--special-floats", "null", "--timestamps", "epoch",
+				".a = 1 | .d += 60 | .f |= ascii_upcase"},
+			"a: 1 # big\nb: -.Inf\nc: .nan\nd: 1008288060\n" +
+				"e: 2001-12-14T21:59:43.10-05:00\nf: HELLO\n" +
+				"g: 1969-12-31T23:59:59.5Z\nh: !!binary /9j/4AAQ\n",
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var b bytes.Buffer
+			err := y.engine.run(nil, strings.NewReader(input), &b)
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != b.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, b.String())
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected run to return an error and it did not")
+			}
+		})
+	}
+}
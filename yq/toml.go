@@ -0,0 +1,435 @@
+package yq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// tomlDecoder decodes a TOML document into the node of its JSON
+// representation. Datetimes, which JSON has no type for, are strings in
+// the format they are written in: RFC 3339 for offset datetimes, and the
+// same without the offset, the time or the date for local datetimes, local
+// dates and local times. Their nodes are tagged tomlDatetimeTag, which
+// keepLiterals gives back to the datetimes the filter did not modify.
+type tomlDecoder struct {
+	r    io.Reader
+	done bool
+}
+
+func newTOMLDecoder(r io.Reader) *tomlDecoder {
+	return &tomlDecoder{r: r}
+}
+
+// Decode decodes the document into v, a *yaml.Node. A TOML input holds a
+// single document.
+func (d *tomlDecoder) Decode(v interface{}) error {
+	if d.done {
+		return io.EOF
+	}
+	d.done = true
+
+	data, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	var table map[string]interface{}
+	md, err := toml.Decode(string(data), &table)
+	if err, ok := err.(toml.ParseError); ok {
+		return &DecodeError{Format: "toml", Line: err.Position.Line,
+			Column: err.Position.Col, Message: err.Message}
+	}
+	if err != nil {
+		return &DecodeError{Format: "toml", Message: err.Error()}
+	}
+
+	// The decoded tables lose the order of their keys, which the metadata
+	// keeps in the order they appear in the document.
+	order := tomlKeyOrder{}
+	for _, key := range md.Keys() {
+		parent := strings.Join(key[:len(key)-1], "\x00")
+		if _, ok := order[parent]; !ok {
+			order[parent] = map[string]int{}
+		}
+		if _, ok := order[parent][key[len(key)-1]]; !ok {
+			order[parent][key[len(key)-1]] = len(order[parent])
+		}
+	}
+
+	*v.(*yaml.Node) = yaml.Node{Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{order.node(table, nil)}}
+	return nil
+}
+
+// tomlKeyOrder holds the index of the keys of every table, by the path of
+// the table joined with NUL characters. The tables of an array share the
+// same path.
+type tomlKeyOrder map[string]map[string]int
+
+func (o tomlKeyOrder) node(v interface{}, path []string) *yaml.Node {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		index := o[strings.Join(path, "\x00")]
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, aok := index[keys[i]]
+			b, bok := index[keys[j]]
+			if aok && bok {
+				return a < b
+			}
+			if aok != bok {
+				return aok
+			}
+			return keys[i] < keys[j]
+		})
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content, stringNode(key),
+				o.node(v[key], append(path[:len(path):len(path)], key)))
+		}
+		return node
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, o.node(item, path))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, o.node(item, path))
+		}
+		return node
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode,
+			Value: strconv.FormatInt(v, 10)}
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: ".nan"}
+		case math.IsInf(v, 1):
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: ".inf"}
+		case math.IsInf(v, -1):
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: "-.inf"}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: formatNumber(v)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatBool(v)}
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tomlDatetimeTag,
+			Value: v.Format(tomlDatetimeLayout(v))}
+	case string:
+		return stringNode(v)
+	}
+	panic(fmt.Sprintf("unexpected TOML value of type %T", v))
+}
+
+// tomlDatetimeTag is the tag of the nodes of TOML datetimes. YAML decodes
+// nodes with a tag it does not know as strings.
+const tomlDatetimeTag = "!toml-datetime"
+
+// tomlDatetimeLayout returns the layout t was written in, which the TOML
+// decoder records in the name of its location.
+func tomlDatetimeLayout(t time.Time) string {
+	switch t.Location().String() {
+	case "datetime-local":
+		return "2006-01-02T15:04:05.999999999"
+	case "date-local":
+		return "2006-01-02"
+	case "time-local":
+		return "15:04:05.999999999"
+	}
+	return time.RFC3339Nano
+}
+
+// tomlDatetime matches the datetimes TOML can write.
+var tomlDatetime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}` +
+	`(T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?)?` +
+	`|\d{2}:\d{2}:\d{2}(\.\d+)?)$`)
+
+// isTOMLDatetime reports whether s is a datetime written the way TOML
+// writes them.
+func isTOMLDatetime(s string) bool {
+	if !tomlDatetime.MatchString(s) {
+		return false
+	}
+	for _, layout := range []string{time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999", "2006-01-02", "15:04:05.999999999"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// tomlEncoder writes documents as TOML. Each document must be an object,
+// the root table. TOML has no null. Datetimes are written for the
+// datetimes of a TOML input the filter did not modify, and for YAML
+// timestamps; strings are always strings.
+type tomlEncoder struct {
+	w       io.Writer
+	buf     bytes.Buffer
+	written bool
+}
+
+var errTOMLRoot = errors.New("toml: the documents must be objects")
+
+// Encode writes doc, a DocumentNode. Documents are separated by an empty
+// line.
+func (e *tomlEncoder) Encode(doc *yaml.Node) error {
+	node := resolve(doc)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return errTOMLRoot
+	}
+
+	e.buf.Reset()
+	if e.written {
+		e.buf.WriteString("\n")
+	}
+	if err := e.table(node, nil); err != nil {
+		return err
+	}
+	e.written = true
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+// table writes the body of the table at path: its keys holding values
+// first, then its tables and arrays of tables, which the values would
+// otherwise belong to. Tables holding nothing but tables are left implicit.
+func (e *tomlEncoder) table(node *yaml.Node, path []string) error {
+	pairs, err := mappingPairs(node)
+	if err != nil {
+		return err
+	}
+
+	var tables []pair
+	for _, p := range pairs {
+		value := resolve(p.value)
+		if value.Kind == yaml.MappingNode || isArrayOfTables(value) {
+			tables = append(tables, pair{p.key, value})
+			continue
+		}
+		e.buf.WriteString(tomlKey(p.key) + " = ")
+		if err := e.value(value, append(path[:len(path):len(path)], p.key)); err != nil {
+			return err
+		}
+		e.buf.WriteString("\n")
+	}
+
+	for _, p := range tables {
+		key := append(path[:len(path):len(path)], p.key)
+		header := make([]string, len(key))
+		for i, k := range key {
+			header[i] = tomlKey(k)
+		}
+
+		items := []*yaml.Node{p.value}
+		open, close := "[", "]"
+		if p.value.Kind == yaml.SequenceNode {
+			items = p.value.Content
+			open, close = "[[", "]]"
+		}
+		for _, item := range items {
+			item = resolve(item)
+			if open == "[[" || !isImplicitTable(item) {
+				if e.buf.Len() > 0 {
+					e.buf.WriteString("\n")
+				}
+				e.buf.WriteString(open + strings.Join(header, ".") + close + "\n")
+			}
+			if err := e.table(item, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isImplicitTable reports whether node, a mapping, only holds tables and
+// arrays of tables, whose headers define it.
+func isImplicitTable(node *yaml.Node) bool {
+	pairs, err := mappingPairs(node)
+	if err != nil {
+		return false
+	}
+	for _, p := range pairs {
+		value := resolve(p.value)
+		if value.Kind != yaml.MappingNode && !isArrayOfTables(value) {
+			return false
+		}
+	}
+	return len(pairs) > 0
+}
+
+// isArrayOfTables reports whether node is a non-empty sequence of
+// mappings, written as an array of tables.
+func isArrayOfTables(node *yaml.Node) bool {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if resolve(item).Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// value writes node, found at path, inline: collections are arrays and
+// inline tables.
+func (e *tomlEncoder) value(node *yaml.Node, path []string) error {
+	node = resolve(node)
+	switch node.Kind {
+	case yaml.SequenceNode:
+		e.buf.WriteString("[")
+		for i, item := range node.Content {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			if err := e.value(item, path); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteString("]")
+		return nil
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(node)
+		if err != nil {
+			return err
+		}
+		if len(pairs) == 0 {
+			e.buf.WriteString("{}")
+			return nil
+		}
+		e.buf.WriteString("{ ")
+		for i, p := range pairs {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			e.buf.WriteString(tomlKey(p.key) + " = ")
+			if err := e.value(p.value, append(path[:len(path):len(path)], p.key)); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteString(" }")
+		return nil
+	}
+
+	s, err := tomlScalar(node)
+	if err != nil {
+		return fmt.Errorf("toml: %s: %v", strings.Join(path, "."), err)
+	}
+	e.buf.WriteString(s)
+	return nil
+}
+
+// tomlScalar returns the TOML representation of a scalar node.
+func tomlScalar(node *yaml.Node) (string, error) {
+	if isNull(node) {
+		return "", errors.New("null cannot be represented in TOML")
+	}
+	if node.Tag == tomlDatetimeTag && isTOMLDatetime(node.Value) {
+		return node.Value, nil
+	}
+	if outOfRange(node) {
+		return "", fmt.Errorf("%s does not fit in a TOML float", node.Value)
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return "", fmt.Errorf("%d does not fit in a TOML integer", v)
+		}
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan", nil
+		case math.IsInf(v, 1):
+			return "inf", nil
+		case math.IsInf(v, -1):
+			return "-inf", nil
+		}
+		s := formatNumber(v)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s, nil
+	case time.Time:
+		if isTOMLDatetime(node.Value) {
+			return node.Value, nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case string:
+		return tomlString(v), nil
+	}
+	return "", fmt.Errorf("unexpected value %q", node.Value)
+}
+
+// tomlBareKey matches the keys that need no quotes.
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
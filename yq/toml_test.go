@@ -0,0 +1,178 @@
+package yq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+func TestTOMLDecoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		toml            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Keeps the order of keys and tables",
+			`zulu = 1
+alpha = "a"
+
+[[fruit]]
+name = "apple"
+[fruit.physical]
+color = "red"
+
+[[fruit]]
+name = "banana"
+
+[database.servers]
+ip = "10.0.0.1"`,
+			`{"zulu":1,"alpha":"a","fruit":[{"name":"apple","physical":` +
+				`{"color":"red"}},{"name":"banana"}],"database":{"servers":` +
+				`{"ip":"10.0.0.1"}}}`,
+			false,
+		},
+		{
+			"Reads inline tables and arrays",
+			`point = { y = 2, x = 1.5, tags = ["a", { k = true }] }`,
+			`{"point":{"y":2,"x":1.5,"tags":["a",{"k":true}]}}`,
+			false,
+		},
+		{
+			"Reads datetimes as strings",
+			`odt = 1979-05-27T07:32:00.5-08:00
+ldt = 1979-05-27T07:32:00
+ld = 1979-05-27
+lt = 07:32:00`,
+			`{"odt":"1979-05-27T07:32:00.5-08:00","ldt":"1979-05-27T07:32:00",` +
+				`"ld":"1979-05-27","lt":"07:32:00"}`,
+			false,
+		},
+		{
+			"Reads an empty document as an empty table",
+			"# nothing\n",
+			`{}`,
+			false,
+		},
+		{
+			"Errors on invalid TOML",
+			"a = 1\nb = \n",
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			tt := jqPipe{documentOptions: documentOptions{inputFormat: "toml"}}
+			err := tt.toJSON(strings.NewReader(tCase.toml), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toJSON to return an error and it did not")
+			}
+		})
+	}
+}
+
+func TestTOMLEncoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		yaml            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Writes values before tables and arrays of tables",
+			`{owner: {name: Tom}, title: x, fruit: [{name: apple}, {name: pear}], ` +
+				`n: 1.0, "a key": [1, {k: true}]}`,
+			`title = "x"
+n = 1.0
+"a key" = [1, { k = true }]
+
+[owner]
+name = "Tom"
+
+[[fruit]]
+name = "apple"
+
+[[fruit]]
+name = "pear"`,
+			false,
+		},
+		{
+			"Leaves tables holding only tables implicit",
+			`{database: {servers: {alpha: {ip: 10.0.0.1}}, empty: {}}}`,
+			`[database.servers.alpha]
+ip = "10.0.0.1"
+
+[database.empty]`,
+			false,
+		},
+		{
+			"Writes timestamps as datetimes and escapes strings",
+			`{ld: 1979-05-27, odt: 1979-05-27T07:32:00Z, d: "1979-05-27", s: "a \"b\"\n"}`,
+			`ld = 1979-05-27
+odt = 1979-05-27T07:32:00Z
+d = "1979-05-27"
+s = "a \"b\"\n"`,
+			false,
+		},
+		{
+			"Errors on null",
+			`{a: null}`,
+			"",
+			true,
+		},
+		{
+			"Errors on numbers out of the range of floats",
+			`{a: !!float 1e400}`,
+			"",
+			true,
+		},
+		{
+			"Errors on documents other than objects",
+			`[1]`,
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tCase.yaml), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			var b bytes.Buffer
+			enc := newEncoder(&b, outputFormat{syntax: "toml"})
+			err := enc.Encode(&doc)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected Encode to return an error and it did not")
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package yq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// Options configures a Transcoder. The zero value reads every input in the
+// format told by its name or content and writes the results as JSON texts,
+// one per line, the way jq does.
+type Options struct {
+	// InputFormat is the format of every input: yaml, json, toml, xml or
+	// csv. When it is empty, the format of an input is the one its name
+	// stands for, or else the one its content looks like.
+	InputFormat string
+	// OutputFormat is the format of the results: json, the default, yaml,
+	// xml or toml. The comments of the input documents are kept in YAML.
+	OutputFormat string
+
+	// Compact writes JSON texts on a single line, as jq's -c.
+	Compact bool
+	// Raw writes the results that are strings without quotes, as jq's -r.
+	Raw bool
+	// Slurp runs the filter once on an array of every document, as jq's -s.
+	Slurp bool
+	// NullInput runs the filter once on null, as jq's -n, leaving the
+	// documents to input and inputs.
+	NullInput bool
+	// SortKeys sorts the keys of objects, as jq's -S.
+	SortKeys bool
+	// Indent is the number of spaces to indent the results with, at most 7,
+	// or 2 when zero.
+	Indent int
+
+	// PreserveTags and PreserveAnchors show the custom tags, anchors and
+	// aliases of YAML documents as objects, as --preserve-tags and
+	// --preserve-anchors.
+	PreserveTags    bool
+	PreserveAnchors bool
+	// MergeKeys is what to do with YAML merge keys: expand, the default,
+	// preserve or reject.
+	MergeKeys string
+	// KeyPolicy is what to do with mapping keys that are not strings:
+	// error, the default, stringify or skip, as --key-policy.
+	KeyPolicy string
+	// SpecialFloats is what to do with .inf, -.inf and .nan: error, the
+	// default, string or null, as --special-floats.
+	SpecialFloats string
+	// Timestamps is what timestamps are: string, the default, or epoch, as
+	// --timestamps.
+	Timestamps string
+	// Binary is what !!binary values are: string, the default, or base64,
+	// as --binary.
+	Binary string
+	// RestoreIntKeys writes the keys that are decimal integers as integers
+	// in YAML, as --restore-int-keys.
+	RestoreIntKeys bool
+	// EmptyDocs is what to do with empty and null documents: skip, the
+	// default, or null, as --empty-docs.
+	EmptyDocs string
+	// WithFilename shows each document as {"file", "doc", "value"}, as
+	// --with-filename.
+	WithFilename bool
+
+	// Variables are the variables the filter can use, by name without the
+	// leading $, as jq's --argjson defines them. Their values are those
+	// encoding/json encodes.
+	Variables map[string]interface{}
+}
+
+// Input is an input of a Transcoder: the documents read from Reader or, if
+// it is nil, from the file called Name. Name also tells the format of the
+// documents when Options.InputFormat is not set, and locates errors. An
+// input without a name is called <stdin> in errors.
+type Input struct {
+	Name   string
+	Reader io.Reader
+}
+
+// Transcoder runs jq filters over YAML, JSON, TOML, XML and CSV documents
+// with the embedded jq, as the yq command does. It is safe for concurrent
+// use.
+type Transcoder struct {
+	// yq holds the options as the command line sets them, and is only read
+	// once set.
+	yq *yq
+}
+
+// NewTranscoder returns a Transcoder configured with options, or an error if
+// they are invalid.
+func NewTranscoder(options Options) (*Transcoder, error) {
+	t := &Transcoder{yq: &yq{}}
+	y := t.yq
+
+	switch options.InputFormat {
+	case "", "yaml", "json", "toml", "xml", "csv":
+		y.inputFormat = options.InputFormat
+	default:
+		return nil, fmt.Errorf("unknown input format %q", options.InputFormat)
+	}
+	switch options.OutputFormat {
+	case "", "json":
+	case "yaml":
+		y.returnYAML = true
+	case "xml", "toml":
+		y.returnYAML, y.format.syntax = true, options.OutputFormat
+	default:
+		return nil, fmt.Errorf("unknown output format %q", options.OutputFormat)
+	}
+	switch options.MergeKeys {
+	case "", "expand", "preserve", "reject":
+		y.mergeKeys = options.MergeKeys
+	default:
+		return nil, fmt.Errorf("unknown merge keys mode %q", options.MergeKeys)
+	}
+	switch options.KeyPolicy {
+	case "", "error", "stringify", "skip":
+		y.keyPolicy = options.KeyPolicy
+	default:
+		return nil, fmt.Errorf("unknown key policy %q", options.KeyPolicy)
+	}
+	switch options.SpecialFloats {
+	case "", "error", "string", "null":
+		y.scalars.specialFloats = options.SpecialFloats
+	default:
+		return nil, fmt.Errorf("unknown special floats policy %q", options.SpecialFloats)
+	}
+	switch options.Timestamps {
+	case "", "string", "epoch":
+		y.scalars.timestamps = options.Timestamps
+	default:
+		return nil, fmt.Errorf("unknown timestamps policy %q", options.Timestamps)
+	}
+	switch options.Binary {
+	case "", "string", "base64":
+		y.scalars.binary = options.Binary
+	default:
+		return nil, fmt.Errorf("unknown binary policy %q", options.Binary)
+	}
+	switch options.EmptyDocs {
+	case "", "skip", "null":
+		y.emptyDocs = options.EmptyDocs
+	default:
+		return nil, fmt.Errorf("unknown empty documents policy %q", options.EmptyDocs)
+	}
+	if options.Indent < 0 || options.Indent > 7 {
+		return nil, fmt.Errorf("indent %d is not between 0 and 7", options.Indent)
+	}
+
+	y.compact = options.Compact
+	y.raw = options.Raw
+	y.slurp = options.Slurp
+	y.nullAsSingleInputValue = options.NullInput
+	y.sort = options.SortKeys
+	y.monochrome = true
+	y.indent = options.Indent
+	if y.indent == 0 {
+		y.indent = 2
+	}
+	y.format.indent = y.indent
+	y.properties = nodeProperties{options.PreserveTags, options.PreserveAnchors}
+	y.restoreIntKeys = options.RestoreIntKeys
+	y.withFilename = options.WithFilename
+
+	names := make([]string, 0, len(options.Variables))
+	for name := range options.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := json.Marshal(options.Variables[name])
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", name, err)
+		}
+		y.variables = append(y.variables, jqVariable{"--argjson", name, string(value)})
+	}
+	return t, nil
+}
+
+// Run runs filter over the documents of inputs and returns its results,
+// until ctx is done. It stops at the first error: a *CompileError if the
+// filter is invalid, a *DecodeError if an input is, a *RuntimeError if the
+// filter fails on a document, or the error of ctx. The results written
+// before a RuntimeError are returned along with it.
+func (t *Transcoder) Run(ctx context.Context, inputs []Input, filter string) ([]byte, error) {
+	e, err := newEngine(t.yq, filter)
+	if err != nil {
+		return nil, err
+	}
+	e.stopOnError, e.stderr = true, ioutil.Discard
+
+	var out bytes.Buffer
+	if err := e.runInputs(ctx, inputs, &out); err != nil {
+		return out.Bytes(), err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package yq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		options         Options
+		inputs          []Input
+		filter          string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Writes JSON by default",
+			Options{Compact: true},
+			[]Input{{Reader: strings.NewReader("a: 1\n---\na: 2\n")}},
+			".a",
+			"1\n2\n",
+			false,
+		},
+		{
+			"Writes YAML keeping comments",
+			Options{OutputFormat: "yaml"},
+			[]Input{{Reader: strings.NewReader("# head\na: 1 # one\nb: 2\n")}},
+			".a += 1",
+			"# head\na: 2 # one\nb: 2\n",
+			false,
+		},
+		{
+			"Tells the format of inputs from their name",
+			Options{Compact: true, Slurp: true},
+			[]Input{
+				{Name: "a.toml", Reader: strings.NewReader("a = 1\n")},
+				{Name: "b.json", Reader: strings.NewReader(`{"b": 2}`)},
+			},
+			"add",
+			`{"a":1,"b":2}` + "\n",
+			false,
+		},
+		{
+			"Reads files when there is no reader",
+			Options{Raw: true},
+			[]Input{{Name: "test_resources/value.json"}},
+			"input_filename",
+			"test_resources/value.json\n",
+			false,
+		},
+		{
+			"Defines variables",
+			Options{Compact: true, NullInput: true,
+				Variables: map[string]interface{}{"a": 1, "b": []string{"x"}}},
+			nil,
+			"[$a, $b]",
+			`[1,["x"]]` + "\n",
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			tr, err := NewTranscoder(tCase.options)
+			if err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			out, err := tr.Run(context.Background(), tCase.inputs, tCase.filter)
+			if err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			if tCase.expected != string(out) {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, string(out))
+			}
+		})
+	}
+}
+
+func TestTranscoderErrors(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		inputs          []Input
+		filter          string
+		check           func(error) bool
+	}
+	testcases := []testCase{
+		{
+			"Returns compile errors",
+			nil,
+			".[",
+			func(err error) bool { _, ok := err.(*CompileError); return ok },
+		},
+		{
+			"Returns decode errors with their location",
+			[]Input{{Name: "a.yaml", Reader: strings.NewReader("a: 1\n---\n[\n")}},
+			".",
+			func(err error) bool {
+				e, ok := err.(*DecodeError)
+				return ok && e.File == "a.yaml" && e.Document == 2
+			},
+		},
+		{
+			"Returns runtime errors with their input",
+			[]Input{{Name: "a.yaml", Reader: strings.NewReader("a: x\n")}},
+			".a + 1",
+			func(err error) bool {
+				e, ok := err.(*RuntimeError)
+				return ok && e.File == "a.yaml"
+			},
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			tr, err := NewTranscoder(Options{})
+			if err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			_, err = tr.Run(context.Background(), tCase.inputs, tCase.filter)
+			if !tCase.check(err) {
+				t.Errorf("Unexpected error '%v' (%T)", err, err)
+			}
+		})
+	}
+}
+
+func TestTranscoderOptions(t *testing.T) {
+	for _, options := range []Options{
+		{InputFormat: "ini"},
+		{OutputFormat: "csv"},
+		{MergeKeys: "drop"},
+		{SpecialFloats: "zero"},
+		{Timestamps: "unix"},
+		{Binary: "hex"},
+		{EmptyDocs: "keep"},
+		{Indent: 8},
+		{Variables: map[string]interface{}{"f": func() {}}},
+	} {
+		if _, err := NewTranscoder(options); err == nil {
+			t.Errorf("Expected NewTranscoder to return an error for %+v and it did not", options)
+		}
+	}
+}
+
+func TestTranscoderContext(t *testing.T) {
+	tr, err := NewTranscoder(Options{NullInput: true})
+	if err != nil {
+		t.Fatal("Did not expect an error got: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = tr.Run(ctx, nil, "last(range(1; infinite))")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected '%v' got '%v'", context.DeadlineExceeded, err)
+	}
+}
+
+func ExampleTranscoder() {
+	t, err := NewTranscoder(Options{OutputFormat: "yaml"})
+	if err != nil {
+		panic(err)
+	}
+	out, err := t.Run(context.Background(), []Input{
+		{Name: "deploy.yaml", Reader: strings.NewReader("replicas: 1 # scaled up\n")},
+	}, ".replicas = 3")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(string(out))
+	// Output: replicas: 3 # scaled up
+}
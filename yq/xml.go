@@ -0,0 +1,353 @@
+package yq
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// xmlDecoder decodes an XML document into the node of its JSON
+// representation, following the conventions of xmltodict, which the xq of
+// kislyuk/yq uses: attributes are keys prefixed with @, the text of
+// elements with attributes or children is their #text key, elements
+// holding only text are strings, empty elements are null and repeated
+// elements are lists.
+type xmlDecoder struct {
+	dec  *xml.Decoder
+	done bool
+}
+
+func newXMLDecoder(r io.Reader) *xmlDecoder {
+	return &xmlDecoder{dec: xml.NewDecoder(r)}
+}
+
+// Decode decodes the document into v, a *yaml.Node. An XML input holds a
+// single document.
+func (d *xmlDecoder) Decode(v interface{}) error {
+	if d.done {
+		return io.EOF
+	}
+	d.done = true
+
+	var root *yaml.Node
+	for {
+		tok, err := d.token()
+		if err == io.EOF && root != nil {
+			break
+		}
+		if err == io.EOF {
+			return &DecodeError{Format: "xml", Message: "no root element"}
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil {
+				return d.error("junk after document element")
+			}
+			line, column := d.dec.InputPos()
+			value, err := d.element(t)
+			if err != nil {
+				return err
+			}
+			key := stringNode(xmlName(t.Name))
+			key.Line, key.Column = line, column
+			root = &yaml.Node{Kind: yaml.MappingNode,
+				Content: []*yaml.Node{key, value}}
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return d.error("text outside of the document element")
+			}
+		}
+	}
+
+	*v.(*yaml.Node) = yaml.Node{Kind: yaml.DocumentNode,
+		Content: []*yaml.Node{root}}
+	return nil
+}
+
+// element decodes the content of the element started by start.
+func (d *xmlDecoder) element(start xml.StartElement) (*yaml.Node, error) {
+	line, column := d.dec.InputPos()
+	node := &yaml.Node{Kind: yaml.MappingNode, Line: line, Column: column}
+	lists := map[string]*yaml.Node{}
+	add := func(key string, value *yaml.Node) {
+		if list, ok := lists[key]; ok {
+			list.Content = append(list.Content, value)
+			return
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				list := &yaml.Node{Kind: yaml.SequenceNode,
+					Content: []*yaml.Node{node.Content[i+1], value}}
+				node.Content[i+1] = list
+				lists[key] = list
+				return
+			}
+		}
+		node.Content = append(node.Content, stringNode(key), value)
+	}
+
+	for _, attr := range start.Attr {
+		add("@"+xmlName(attr.Name), stringNode(attr.Value))
+	}
+
+	var text bytes.Buffer
+	for {
+		tok, err := d.token()
+		if err == io.EOF {
+			return nil, d.error(fmt.Sprintf("element <%s> not closed",
+				xmlName(start.Name)))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := d.element(t)
+			if err != nil {
+				return nil, err
+			}
+			add(xmlName(t.Name), child)
+		case xml.EndElement:
+			if xmlName(t.Name) != xmlName(start.Name) {
+				return nil, d.error(fmt.Sprintf("element <%s> closed by </%s>",
+					xmlName(start.Name), xmlName(t.Name)))
+			}
+			s := strings.TrimSpace(text.String())
+			switch {
+			case len(node.Content) == 0 && s == "":
+				return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null",
+					Value: "null", Line: line, Column: column}, nil
+			case len(node.Content) == 0:
+				value := stringNode(s)
+				value.Line, value.Column = line, column
+				return value, nil
+			case s != "":
+				add("#text", stringNode(s))
+			}
+			return node, nil
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+}
+
+// token returns the next token of the document, with the namespace
+// prefixes of names left as they are written.
+func (d *xmlDecoder) token() (xml.Token, error) {
+	tok, err := d.dec.RawToken()
+	if err, ok := err.(*xml.SyntaxError); ok {
+		return nil, &DecodeError{Format: "xml", Line: err.Line, Message: err.Msg}
+	}
+	return tok, err
+}
+
+func (d *xmlDecoder) error(message string) error {
+	line, column := d.dec.InputPos()
+	return &DecodeError{Format: "xml", Line: line, Column: column,
+		Message: message}
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// xmlEncoder writes documents as XML, the way xmltodict unparses them. Each
+// document must be an object with a single key, the name of the root
+// element, unless --xml-root names an element to wrap it in.
+type xmlEncoder struct {
+	w      io.Writer
+	format outputFormat
+	buf    bytes.Buffer
+}
+
+var errXMLRoot = errors.New("xml: the documents need a single root " +
+	"element, use --xml-root to add one")
+
+// Encode writes doc, a DocumentNode.
+func (e *xmlEncoder) Encode(doc *yaml.Node) error {
+	node := resolve(doc)
+	if e.format.xmlRoot != "" {
+		node = &yaml.Node{Kind: yaml.MappingNode,
+			Content: []*yaml.Node{stringNode(e.format.xmlRoot), node}}
+	}
+	if node == nil || node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return errXMLRoot
+	}
+
+	e.buf.Reset()
+	name, err := keyString(resolve(node.Content[0]))
+	if err != nil {
+		return err
+	}
+	if resolve(node.Content[1]).Kind == yaml.SequenceNode {
+		return fmt.Errorf("xml: the root element %s cannot be a list, "+
+			"which would make several root elements", name)
+	}
+	if err := e.element(name, resolve(node.Content[1]), 0); err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf.Bytes())
+	return err
+}
+
+func (e *xmlEncoder) element(name string, node *yaml.Node, depth int) error {
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			if err := e.element(name, resolve(item), depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !isXMLName(name) {
+		return fmt.Errorf("xml: %q is not a valid element name", name)
+	}
+	e.indent(depth)
+	e.buf.WriteString("<" + name)
+	if node.Kind == yaml.ScalarNode {
+		e.buf.WriteString(">")
+		if err := e.text(node); err != nil {
+			return err
+		}
+		e.buf.WriteString("</" + name + ">\n")
+		return nil
+	}
+
+	pairs, err := mappingPairs(node)
+	if err != nil {
+		return err
+	}
+	var text *yaml.Node
+	var children []pair
+	for _, p := range pairs {
+		value := resolve(p.value)
+		switch {
+		case p.key == "#text":
+			text = value
+		case strings.HasPrefix(p.key, "@"):
+			if value.Kind != yaml.ScalarNode {
+				return fmt.Errorf("xml: attribute %s must have a scalar "+
+					"value", p.key[1:])
+			}
+			if !isXMLName(p.key[1:]) {
+				return fmt.Errorf("xml: %q is not a valid attribute name",
+					p.key[1:])
+			}
+			e.buf.WriteString(" " + p.key[1:] + `="`)
+			s, err := xmlText(value)
+			if err != nil {
+				return err
+			}
+			xmlAttrEscaper.WriteString(&e.buf, s)
+			e.buf.WriteString(`"`)
+		default:
+			children = append(children, pair{p.key, value})
+		}
+	}
+	e.buf.WriteString(">")
+	if text != nil {
+		if err := e.text(text); err != nil {
+			return err
+		}
+	}
+	if len(children) > 0 {
+		e.buf.WriteString("\n")
+		for _, child := range children {
+			if err := e.element(child.key, child.value, depth+1); err != nil {
+				return err
+			}
+		}
+		e.indent(depth)
+	}
+	e.buf.WriteString("</" + name + ">\n")
+	return nil
+}
+
+// xmlNameStart and xmlNameRest are the characters names of elements and
+// attributes start with and go on with, NameStartChar and NameChar in the
+// XML specification.
+var (
+	xmlNameStart = &unicode.RangeTable{
+		R16: []unicode.Range16{
+			{':', ':', 1}, {'A', 'Z', 1}, {'_', '_', 1}, {'a', 'z', 1},
+			{0xC0, 0xD6, 1}, {0xD8, 0xF6, 1}, {0xF8, 0x2FF, 1},
+			{0x370, 0x37D, 1}, {0x37F, 0x1FFF, 1}, {0x200C, 0x200D, 1},
+			{0x2070, 0x218F, 1}, {0x2C00, 0x2FEF, 1}, {0x3001, 0xD7FF, 1},
+			{0xF900, 0xFDCF, 1}, {0xFDF0, 0xFFFD, 1},
+		},
+		R32: []unicode.Range32{{0x10000, 0xEFFFF, 1}},
+	}
+	xmlNameRest = &unicode.RangeTable{
+		R16: []unicode.Range16{
+			{'-', '.', 1}, {'0', '9', 1}, {0xB7, 0xB7, 1}, {0x300, 0x36F, 1},
+			{0x203F, 0x2040, 1},
+		},
+	}
+)
+
+// isXMLName reports whether s can name an element or an attribute.
+func isXMLName(s string) bool {
+	for i, r := range s {
+		if !unicode.Is(xmlNameStart, r) && (i == 0 || !unicode.Is(xmlNameRest, r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (e *xmlEncoder) text(node *yaml.Node) error {
+	s, err := xmlText(node)
+	if err != nil {
+		return err
+	}
+	xmlTextEscaper.WriteString(&e.buf, s)
+	return nil
+}
+
+func (e *xmlEncoder) indent(depth int) {
+	indent := e.format.indent
+	if indent == 0 {
+		indent = 2
+	}
+	e.buf.WriteString(strings.Repeat(" ", depth*indent))
+}
+
+// xmlText returns the text of a scalar node: null is empty, and other
+// values are written as they are in JSON.
+func xmlText(node *yaml.Node) (string, error) {
+	if node.Kind != yaml.ScalarNode {
+		return "", errors.New("xml: text must be a scalar")
+	}
+	if isNull(node) {
+		return "", nil
+	}
+	v, err := scalarValue(node)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return node.Value, nil
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;",
+		`"`, "&quot;")
+)
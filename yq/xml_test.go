@@ -0,0 +1,179 @@
+package yq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+func TestXMLDecoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		xml             string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Maps attributes, text and repeated elements like xmltodict",
+			`<?xml version="1.0"?>
+<!-- comment -->
+<root id="1">
+  <item>one</item>
+  <item lang="en">two &amp; more</item>
+  <empty/>
+</root>`,
+			`{"root":{"@id":"1","item":["one",{"@lang":"en","#text":"two & more"}],` +
+				`"empty":null}}`,
+			false,
+		},
+		{
+			"Keeps namespace prefixes",
+			`<a xmlns:h="urn:h"><h:b>x</h:b></a>`,
+			`{"a":{"@xmlns:h":"urn:h","h:b":"x"}}`,
+			false,
+		},
+		{
+			"Keeps the text of elements with children",
+			`<a>text<b>bold</b></a>`,
+			`{"a":{"b":"bold","#text":"text"}}`,
+			false,
+		},
+		{
+			"Errors on mismatched elements",
+			`<a><b></a>`,
+			"",
+			true,
+		},
+		{
+			"Errors on several root elements",
+			`<a/><b/>`,
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			xt := jqPipe{documentOptions: documentOptions{inputFormat: "xml"}}
+			err := xt.toJSON(strings.NewReader(tCase.xml), &b)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toJSON to return an error and it did not")
+			}
+		})
+	}
+}
+
+func TestXMLEncoder(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		yaml            string
+		root            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Writes attributes, text and lists like xmltodict",
+			`{root: {"@id": 1, item: [one, {"@lang": en, "#text": "a & b"}], ` +
+				`empty: null, flag: true}}`,
+			"",
+			`<root id="1">
+  <item>one</item>
+  <item lang="en">a &amp; b</item>
+  <empty></empty>
+  <flag>true</flag>
+</root>`,
+			false,
+		},
+		{
+			"Wraps documents in the root element",
+			`{a: 1, b: 2}`,
+			"doc",
+			`<doc>
+  <a>1</a>
+  <b>2</b>
+</doc>`,
+			false,
+		},
+		{
+			"Errors without a single root element",
+			`{a: 1, b: 2}`,
+			"",
+			"",
+			true,
+		},
+		{
+			"Errors on a root element that is a list",
+			`[1, 2]`,
+			"r",
+			"",
+			true,
+		},
+		{
+			"Errors on a single key holding a list",
+			`{r: [1, 2]}`,
+			"",
+			"",
+			true,
+		},
+		{
+			"Errors on invalid element names",
+			`{"a b": 1}`,
+			"",
+			"",
+			true,
+		},
+		{
+			"Errors on #text without an element",
+			`{"#text": x}`,
+			"",
+			"",
+			true,
+		},
+		{
+			"Errors on invalid attribute names",
+			`{a: {"@1b": x}}`,
+			"",
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tCase.yaml), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			var b bytes.Buffer
+			enc := newEncoder(&b, outputFormat{syntax: "xml", xmlRoot: tCase.root})
+			err := enc.Encode(&doc)
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected Encode to return an error and it did not")
+			}
+		})
+	}
+}
@@ -0,0 +1,1167 @@
+// Package yq runs jq filters over YAML, JSON, TOML, XML and CSV documents,
+// with a Transcoder, and implements the yq command.
+package yq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+type jqFlags struct {
+	compact                     bool
+	nullAsSingleInputValue      bool
+	exitStatusCodeBasedOnOutput bool
+	slurp                       bool
+	raw                         bool
+	join                        bool
+	ascii                       bool
+	rawString                   bool
+	color                       bool
+	monochrome                  bool
+	sort                        bool
+	tab                         bool
+	indent                      int
+	seq                         bool
+	stream                      bool
+	unbuffered                  bool
+	fromFile                    bool
+	libraryPaths                []string
+}
+
+// jqVariable is a variable defined with one of jq's --arg, --argjson,
+// --slurpfile, --argfile or --rawfile flags, which can all be repeated.
+type jqVariable struct {
+	flag  string
+	name  string
+	value string
+}
+
+// jqPositional is a positional parameter, a command line argument following
+// jq's --args or --jsonargs flag rather than an input file.
+type jqPositional struct {
+	flag  string
+	value string
+}
+
+type yq struct {
+	returnYAML     bool
+	inPlace        bool
+	jqBinary       string
+	pipe           jqPipe
+	engine         *engine
+	variables      []jqVariable
+	positional     []jqPositional
+	positionalFlag string
+	errorFormat    string
+	perFile        bool
+	jobs           int
+	filter         string
+	recursive      bool
+	include        []string
+	exclude        []string
+	jqCmd          exec.Cmd
+	jqStdout       io.ReadCloser
+	jqStdinWriter  io.WriteCloser
+	files          []string
+
+	documentOptions
+	jqFlags
+}
+
+// jqPipe converts between the YAML documents read from the input and
+// the JSON texts exchanged with jq. When keepComments is set it remembers the
+// documents it decoded until jq emitted the document each stems from, so
+// that their comments can be reattached to the YAML it emits from jq's
+// output. When seq is set, the JSON texts are prefixed with the ASCII record
+// separator, as jq --seq reads them. Both conversions can run at once,
+// guarded by mu.
+type jqPipe struct {
+	keepComments bool
+	slurp        bool
+	seq          bool
+	file         string
+	mu           sync.Mutex
+	docs         []inputDoc
+	pending      int
+	diverged     bool
+
+	documentOptions
+}
+
+// inputDoc is a document fed to jq, the length of its JSON text, and its
+// valueKey once computed.
+type inputDoc struct {
+	node *yaml.Node
+	size int
+	key  string
+}
+
+// maxPending bounds the length of the JSON texts of the documents fed to jq
+// it emitted no document for yet. Far more than the pipes to and from jq
+// hold, it is only reached when jq dropped documents.
+const maxPending = 8 << 20
+
+func transformToYAML(reader io.Reader, writer io.Writer) error {
+	var t jqPipe
+	return t.toYAML(reader, writer)
+}
+
+func transformToJSON(reader io.Reader, writer io.WriteCloser) error {
+	var t jqPipe
+	return t.toJSON(reader, writer)
+}
+
+func (t *jqPipe) toYAML(reader io.Reader, writer io.Writer) error {
+	dec := json.NewDecoder(reader)
+	dec.UseNumber()
+	enc := newEncoder(writer, t.format)
+	for {
+		node, err := decodeJSONNode(dec)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}
+		var orig *yaml.Node
+		if t.keepComments {
+			orig = t.original(doc)
+		}
+		if err := enc.Encode(t.finish(doc, orig, movedValues(doc, orig))); err != nil {
+			return err
+		}
+	}
+}
+
+// original returns the input document doc, a document jq emitted, stems
+// from, or nil if it is not known. jq does not tell where the documents it
+// emits stem from, but filters that rewrite values in place emit one
+// document for each input document, in order, or a single array holding
+// every input document when slurping. Others, such as select, may drop or
+// add documents, and the comments of a document would end up on another
+// one, so once jq emitted a document when no input document is left, or one
+// holding the value of a later input document, no document is matched
+// anymore.
+func (t *jqPipe) original(doc *yaml.Node) *yaml.Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.diverged || len(t.docs) == 0 {
+		t.diverge()
+		return nil
+	}
+	if t.slurp {
+		seq := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, d := range t.docs {
+			seq.Content = append(seq.Content, resolve(d.node))
+		}
+		t.diverge()
+		return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{seq}}
+	}
+
+	keys := map[*yaml.Node]string{}
+	if len(t.docs) > 1 {
+		key := valueKey(doc, keys)
+		if key != t.docs[0].valueKey(keys) {
+			for i := range t.docs[1:] {
+				if key == t.docs[i+1].valueKey(keys) {
+					t.diverge()
+					return nil
+				}
+			}
+		}
+	}
+	orig := t.docs[0]
+	t.docs[0] = inputDoc{}
+	t.docs = t.docs[1:]
+	t.pending -= orig.size
+	return orig.node
+}
+
+// add remembers doc, fed to jq as a JSON text of size bytes.
+func (t *jqPipe) add(doc *yaml.Node, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.diverged {
+		return
+	}
+	t.docs = append(t.docs, inputDoc{node: doc, size: size})
+	t.pending += size
+	if !t.slurp && len(t.docs) > 1 && t.pending > maxPending {
+		t.diverge()
+	}
+}
+
+// diverge forgets the input documents, the documents jq emits no longer
+// matching them.
+func (t *jqPipe) diverge() {
+	t.docs, t.pending, t.diverged = nil, 0, true
+}
+
+func (d *inputDoc) valueKey(keys map[*yaml.Node]string) string {
+	if d.key == "" {
+		d.key = valueKey(d.node, keys)
+	}
+	return d.key
+}
+
+// decodeJSONNode reads the next JSON value from dec and returns it as a YAML
+// node, keeping object keys in the order jq emitted them.
+func decodeJSONNode(dec *json.Decoder) (*yaml.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, stringNode(key.(string)), value)
+			}
+			_, err = dec.Token()
+			return node, err
+		case '[':
+			node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for dec.More() {
+				value, err := decodeJSONNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, value)
+			}
+			_, err = dec.Token()
+			return node, err
+		}
+		return nil, fmt.Errorf("unexpected JSON delimiter %q", t)
+	case string:
+		return stringNode(t), nil
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: formatNumber(t)}, nil
+	case json.Number:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: t.String()}
+		// YAML resolves numbers out of the range of floats as strings.
+		if node.ShortTag() == "!!str" {
+			node.Tag = "!!float"
+		}
+		return node, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatBool(t)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: "null"}, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+func stringNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// decoder decodes the documents of an input, one at a time, into a
+// *yaml.Node.
+type decoder interface {
+	Decode(v interface{}) error
+}
+
+// newDecoder returns the decoder of the documents of r, written in format:
+// yaml, json, toml, xml or csv.
+func newDecoder(r io.Reader, format string) decoder {
+	switch format {
+	case "json":
+		return newJSONDecoder(r)
+	case "xml":
+		return newXMLDecoder(r)
+	case "toml":
+		return newTOMLDecoder(r)
+	case "csv":
+		return newCSVDecoder(r)
+	}
+	return yaml.NewDecoder(r)
+}
+
+// toJSON writes the documents read from reader as JSON texts, sniffing
+// their format unless inputFormat is set. Errors in the documents are
+// returned as a DecodeError.
+func (t *jqPipe) toJSON(reader io.Reader, writer io.Writer) error {
+	format := t.inputFormat
+	if format == "" {
+		reader, format = sniffFormat(reader)
+	}
+	dec := newDecoder(reader, format)
+	var buf bytes.Buffer
+	for document := 1; ; document++ {
+		doc := &yaml.Node{}
+		if err := dec.Decode(doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return locate(err, format, document)
+		}
+		doc, err := t.prepare(doc, t.file, document)
+		if err != nil {
+			return locate(err, format, document)
+		}
+		if doc == nil {
+			continue
+		}
+		buf.Reset()
+		if t.seq {
+			buf.WriteByte('\x1e')
+		}
+		if err := writeJSON(&buf, t.scalars.apply(doc)); err != nil {
+			return locate(err, format, document)
+		}
+		buf.WriteByte('\n')
+		// jq may emit its result before the write returns.
+		if t.keepComments {
+			t.add(doc, buf.Len())
+		}
+		if _, err := writer.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNull reports whether node, once aliases and documents are unwrapped, is
+// an empty or null scalar.
+func isNull(node *yaml.Node) bool {
+	node = resolve(node)
+	return node == nil || node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// resolve unwraps document and alias nodes down to the node holding the
+// actual content.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+// writeJSON encodes node as compact JSON into buf. Unlike going through
+// interface{}, mapping keys are written in the order they appear in the YAML
+// document.
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	node = resolve(node)
+	if node == nil {
+		buf.WriteString("null")
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(node)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('{')
+		for i, p := range pairs {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeScalarJSON(buf, p.key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, p.value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		v, err := scalarValue(node)
+		if err != nil {
+			return err
+		}
+		return writeScalarJSON(buf, v)
+	}
+	return nil
+}
+
+// scalarValue decodes a scalar node into one of the types jq values are made
+// of: nil, bool, int, float64, *big.Int, or string.
+func scalarValue(node *yaml.Node) (interface{}, error) {
+	if outOfRange(node) {
+		return nil, nodeError(node, "%s cannot be represented in JSON",
+			node.Value)
+	}
+	if node.ShortTag() == "!!binary" {
+		if text, ok := binaryText(node); ok {
+			return text, nil
+		}
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case int64:
+		return int(v), nil
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int(v), nil
+		}
+		return new(big.Int).SetUint64(v), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, nodeError(node, "%s cannot be represented in JSON",
+				node.Value)
+		}
+		if i, ok := bigInt(node); ok {
+			return i, nil
+		}
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return v, nil
+}
+
+// origins maps the objects handed to gojq back to the mapping nodes they
+// were decoded from. gojq returns the very same map for objects a filter
+// does not modify, which lets their original key order be recovered
+// wherever they end up in the output.
+type origins map[uintptr]origin
+
+type origin struct {
+	value map[string]interface{}
+	node  *yaml.Node
+}
+
+func (o origins) add(m map[string]interface{}, node *yaml.Node) {
+	if o != nil {
+		o[reflect.ValueOf(m).Pointer()] = origin{m, node}
+	}
+}
+
+func (o origins) lookup(m map[string]interface{}) *yaml.Node {
+	return o[reflect.ValueOf(m).Pointer()].node
+}
+
+// nodeToValue converts node into the value gojq operates on, following the
+// same rules writeJSON does once scalars mapped them, as scalars.apply
+// does. The objects created are recorded in o along with the mappings of
+// node they were decoded from.
+func (o origins) nodeToValue(node *yaml.Node, scalars scalarPolicy) (interface{}, error) {
+	node = resolve(node)
+	if node == nil {
+		return nil, nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(node)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(pairs))
+		for _, p := range pairs {
+			if m[p.key], err = o.nodeToValue(p.value, scalars); err != nil {
+				return nil, err
+			}
+		}
+		o.add(m, node)
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			v, err := o.nodeToValue(item, scalars)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = v
+		}
+		return s, nil
+	}
+	if scalars.enabled() {
+		if mapped := scalars.scalar(node); mapped != nil {
+			node = mapped
+		}
+	}
+	return scalarValue(node)
+}
+
+// valueToNode converts a value produced by gojq into a YAML node. gojq does
+// not keep track of the order of object keys, so keys that exist in the
+// mapping the object was decoded from, or else in orig, the node at the same
+// path in the input document, are put back in their original order, followed
+// by any new keys in sorted order. The mappings built for objects decoded
+// from a mapping are recorded in moved.
+func (o origins) valueToNode(v interface{}, orig *yaml.Node, sortKeys bool, moved sources) *yaml.Node {
+	orig = resolve(orig)
+
+	switch v := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatBool(v)}
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.Itoa(v)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: formatNumber(v)}
+	case *big.Int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: v.String()}
+	case string:
+		return stringNode(v)
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for i, item := range v {
+			var origItem *yaml.Node
+			if orig != nil && orig.Kind == yaml.SequenceNode && i < len(orig.Content) {
+				origItem = orig.Content[i]
+			}
+			node.Content = append(node.Content, o.valueToNode(item, origItem, sortKeys, moved))
+		}
+		return node
+	case map[string]interface{}:
+		source := o.lookup(v)
+		if source != nil {
+			orig = source
+		}
+		originals := map[string]*yaml.Node{}
+		index := map[string]int{}
+		if orig != nil && orig.Kind == yaml.MappingNode && !sortKeys {
+			pairs, _ := mappingPairs(orig)
+			for i, p := range pairs {
+				originals[p.key] = p.value
+				index[p.key] = i
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, aok := index[keys[i]]
+			b, bok := index[keys[j]]
+			if aok && bok {
+				return a < b
+			}
+			if aok != bok {
+				return aok
+			}
+			return keys[i] < keys[j]
+		})
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content,
+				stringNode(key), o.valueToNode(v[key], originals[key], sortKeys, moved))
+		}
+		if source != nil {
+			moved.add(node, source)
+		}
+		return node
+	}
+	panic(fmt.Sprintf("unexpected value of type %T", v))
+}
+
+// formatNumber formats f the way jq prints numbers: the shortest
+// representation that round trips, switching to exponent notation for very
+// small and very large magnitudes.
+func formatNumber(f float64) string {
+	if math.IsNaN(f) {
+		return "null"
+	}
+	if f > math.MaxFloat64 {
+		f = math.MaxFloat64
+	} else if f < -math.MaxFloat64 {
+		f = -math.MaxFloat64
+	}
+	if f == 0 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent := e[:strings.IndexByte(e, 'e')], e[strings.IndexByte(e, 'e')+1:]
+	digits := len(strings.TrimLeft(strings.Replace(mantissa, ".", "", 1), "-"))
+	point, _ := strconv.Atoi(exponent)
+	point++
+	if point > -4 && point <= digits+15 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return e
+}
+
+func writeScalarJSON(buf *bytes.Buffer, v interface{}) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// json.Encoder always terminates values with a newline.
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+type pair struct {
+	key   string
+	value *yaml.Node
+}
+
+// mappingPairs returns the key/value pairs of a mapping node in document
+// order. Merge keys (<<) are expanded in place, with keys defined directly
+// on the mapping taking precedence over merged ones and earlier merge
+// sources taking precedence over later ones.
+func mappingPairs(node *yaml.Node) ([]pair, error) {
+	explicit := map[string]int{}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := resolve(node.Content[i])
+		if isMerge(key) {
+			continue
+		}
+		name, err := keyString(key)
+		if err != nil {
+			return nil, err
+		}
+		if line, ok := explicit[name]; ok {
+			return nil, nodeError(key, "mapping key %q already defined at "+
+				"line %d", name, line)
+		}
+		explicit[name] = key.Line
+	}
+
+	var pairs []pair
+	seen := map[string]bool{}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := resolve(node.Content[i]), node.Content[i+1]
+		if !isMerge(key) {
+			name, _ := keyString(key)
+			pairs = append(pairs, pair{name, value})
+			seen[name] = true
+			continue
+		}
+
+		merged, err := mergePairs(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range merged {
+			if _, ok := explicit[p.key]; ok || seen[p.key] {
+				continue
+			}
+			pairs = append(pairs, p)
+			seen[p.key] = true
+		}
+	}
+	return pairs, nil
+}
+
+// mergePairs returns the pairs contributed by the value of a merge key,
+// which is either a mapping or a sequence of mappings.
+func mergePairs(value *yaml.Node) ([]pair, error) {
+	value = resolve(value)
+	switch value.Kind {
+	case yaml.MappingNode:
+		return mappingPairs(value)
+	case yaml.SequenceNode:
+		var pairs []pair
+		for _, item := range value.Content {
+			item = resolve(item)
+			if item.Kind != yaml.MappingNode {
+				return nil, nodeError(item, "map merge requires map or "+
+					"sequence of maps as the value")
+			}
+			p, err := mappingPairs(item)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, p...)
+		}
+		return pairs, nil
+	}
+	return nil, nodeError(value, "map merge requires map or sequence of "+
+		"maps as the value")
+}
+
+func isMerge(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && key.Value == "<<" &&
+		(key.Tag == "" || key.Tag == "!" || key.ShortTag() == "!!merge")
+}
+
+// keyString returns the value of a mapping key, which must be a string for
+// the mapping to be representable as a JSON object.
+func keyString(key *yaml.Node) (string, error) {
+	var v interface{}
+	if key.Kind == yaml.ScalarNode {
+		if err := key.Decode(&v); err != nil {
+			return "", err
+		}
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	}
+	return "", nodeError(key, "mapping key %q is not a string, which JSON "+
+		"does not support", key.Value)
+}
+
+func (yq *yq) compileJqCmd(osArgs []string, stderr io.Writer) error {
+	args, err := yq.parseFlags(osArgs, stderr)
+	if err != nil {
+		return err
+	}
+
+	if yq.jqBinary != "" {
+		path, err := exec.LookPath(yq.jqBinary)
+		if err != nil {
+			return err
+		}
+		yq.jqCmd.Path = path
+	} else if yq.jqCmd.Path == "" {
+		yq.jqCmd.Path = "jq"
+	}
+
+	yq.jqCmd.Args = append(yq.jqCmd.Args, yq.jqCmd.Path)
+	if yq.compact {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-c")
+	}
+	if yq.nullAsSingleInputValue {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-n")
+	}
+	if yq.exitStatusCodeBasedOnOutput {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-e")
+	}
+	if yq.raw {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-r")
+	}
+	if yq.join {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-j")
+	}
+	if yq.ascii {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-a")
+	}
+	if yq.slurp {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-s")
+	}
+	if yq.rawString {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-R")
+	}
+	// jq's colors would break decoding its output back into YAML, which yq
+	// colors itself.
+	if yq.color && !yq.returnYAML {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-C")
+	}
+	if yq.monochrome {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-M")
+	}
+	if yq.sort {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-S")
+	}
+	if yq.tab {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "--tab")
+	}
+	if yq.indent != 2 {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "--indent", strconv.Itoa(yq.indent))
+	}
+	// jq reads the texts of its input as it writes them with --seq, each
+	// prefixed with the record separator, which yq adds. jq's separators
+	// would break decoding its output back into YAML, which has none.
+	if yq.seq && !yq.returnYAML {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "--seq")
+		yq.pipe.seq = !yq.rawString
+	}
+	if yq.stream {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "--stream")
+	}
+	if yq.unbuffered {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "--unbuffered")
+	}
+	for _, dir := range yq.libraryPaths {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-L", dir)
+	}
+	for _, v := range yq.variables {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, v.flag, v.name, v.value)
+	}
+	if yq.fromFile {
+		yq.jqCmd.Args = append(yq.jqCmd.Args, "-f")
+	}
+
+	filter := args[0]
+	yq.filter = filter
+	yq.jqCmd.Args = append(yq.jqCmd.Args, filter)
+	flag := ""
+	for _, p := range yq.positional {
+		if p.flag != flag {
+			yq.jqCmd.Args = append(yq.jqCmd.Args, p.flag)
+			flag = p.flag
+		}
+		yq.jqCmd.Args = append(yq.jqCmd.Args, p.value)
+	}
+	if yq.fromFile {
+		data, err := ioutil.ReadFile(filter)
+		if err != nil {
+			return err
+		}
+		filter = string(data)
+		yq.filter = filter
+	}
+
+	seen := map[string]bool{}
+	for _, arg := range args[1:] {
+		if err := yq.addInput(arg, seen); err != nil {
+			return err
+		}
+	}
+
+	if yq.inPlace {
+		if len(yq.files) == 0 {
+			return errors.New("-i requires at least one file to edit")
+		}
+		yq.returnYAML = true
+	}
+	if yq.perFile && len(yq.files) == 0 {
+		return errors.New("--per-file requires at least one file")
+	}
+	if !yq.tab {
+		yq.format.indent = yq.indent
+	}
+	setColors(stderr)
+	yq.format.color = yq.returnYAML && !yq.inPlace && colorize(yq.jqFlags)
+
+	if yq.jqBinary == "" {
+		engine, err := newEngine(yq, filter)
+		if err != nil {
+			return compileError(err)
+		}
+		yq.engine = engine
+		return nil
+	}
+
+	if yq.jqStdinWriter, err = yq.jqCmd.StdinPipe(); err != nil {
+		return err
+	}
+
+	yq.pipe.documentOptions = yq.documentOptions
+	if yq.returnYAML {
+		yq.pipe.keepComments = true
+		yq.pipe.slurp = yq.slurp
+
+		var stdoutPipe io.ReadCloser
+		stdoutPipe, err := yq.jqCmd.StdoutPipe()
+
+		if err != nil {
+			return err
+		}
+		yq.jqStdout = stdoutPipe
+	}
+
+	yq.jqCmd.Stderr = os.Stderr
+
+	return nil
+}
+
+func (yq *yq) run() error {
+	if yq.inPlace {
+		return yq.runInPlace()
+	}
+	if yq.perFile {
+		return yq.runPerFile(os.Stdout, os.Stderr)
+	}
+
+	if yq.engine != nil {
+		out := bufio.NewWriter(os.Stdout)
+		if err := yq.engine.run(yq.files, os.Stdin, out); err != nil {
+			out.Flush()
+			return err
+		}
+		return out.Flush()
+	}
+
+	return yq.runJq(os.Stdin, os.Stdout)
+}
+
+// runJq runs the jq binary over the input files, or stdin if there are
+// none, and writes its output to stdout. The inputs are fed to jq while its
+// output is read, so that neither waits on the other whatever their size:
+// a goroutine writes the inputs to jq's stdin while the output is
+// transcoded back into YAML. A failure writing the output kills jq, which
+// in turn makes the feeding fail on a broken pipe.
+func (yq *yq) runJq(stdin io.Reader, stdout io.Writer) error {
+	if !yq.returnYAML {
+		yq.jqCmd.Stdout = stdout
+	}
+	if err := yq.jqCmd.Start(); err != nil {
+		return err
+	}
+
+	fed := make(chan error, 1)
+	go func() {
+		err := yq.writeInputs(stdin)
+		yq.jqStdinWriter.Close()
+		fed <- err
+	}()
+
+	if yq.returnYAML {
+		if err := yq.pipe.toYAML(yq.jqStdout, stdout); err != nil {
+			yq.jqCmd.Process.Kill()
+			yq.jqCmd.Wait()
+			return err
+		}
+	}
+
+	// A failing jq reported why on stderr, and its status also explains any
+	// broken pipe met writing its input. jq may also succeed without
+	// reading all of it, e.g. with -n and input.
+	err := <-fed
+	if waitErr := yq.jqCmd.Wait(); waitErr != nil {
+		return jqExitError(waitErr)
+	}
+	if isBrokenPipe(err) {
+		return nil
+	}
+	return err
+}
+
+// isBrokenPipe tells whether err is the error writing to a pipe nobody
+// reads anymore.
+func isBrokenPipe(err error) bool {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+	return err == syscall.EPIPE
+}
+
+// writeInputs transcodes the documents of the input files, or of stdin if
+// there are no files, into jq's input.
+func (yq *yq) writeInputs(stdin io.Reader) error {
+	if len(yq.files) == 0 {
+		return inFile(yq.pipe.toJSON(stdin, yq.jqStdinWriter), "<stdin>")
+	}
+
+	for _, path := range yq.files {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		yq.pipe.inputFormat = fileFormat(path, yq.inputFormat)
+		yq.pipe.file = path
+		err = yq.pipe.toJSON(file, yq.jqStdinWriter)
+		file.Close()
+		if err != nil {
+			return inFile(err, path)
+		}
+	}
+	return nil
+}
+
+// jqExitError converts the error of a jq process that exited with a non-zero
+// status into an exitError, so that yq exits with the same status.
+func jqExitError(err error) error {
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() > 0 {
+		return &exitError{status: exit.ExitCode()}
+	}
+	return err
+}
+
+// runInPlace runs the filter separately over each file and replaces the
+// files with the YAML results. Every file is filtered before any of them is
+// written, so that an error leaves all of them untouched.
+func (yq *yq) runInPlace() error {
+	results := make([][]byte, len(yq.files))
+	var failure error
+	err := yq.filterFiles(true, func(i int, r *fileRun) bool {
+		os.Stderr.Write(r.stderr.Bytes())
+		failure = r.err
+		results[i] = r.stdout.Bytes()
+		return failure == nil
+	})
+	if err != nil {
+		return err
+	}
+	if failure != nil {
+		return failure
+	}
+
+	for i, file := range yq.files {
+		if err := writeFileAtomic(file, results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterFile runs the filter over a single file, with e or, when using the
+// jq binary, a dedicated jq process, and writes the results to w and the
+// errors jq reports to stderr. In place, the results are written back in
+// the format of the file instead of the output format.
+func (yq *yq) filterFile(e *engine, path string, inPlace bool, w, stderr io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	inputFormat := fileFormat(path, yq.inputFormat)
+	format := yq.format
+	if inPlace {
+		if inputFormat == "" {
+			reader, inputFormat = sniffFormat(file)
+		}
+		if inputFormat == "csv" {
+			return fmt.Errorf("%s: -i cannot write CSV files", path)
+		}
+		format.syntax = inputFormat
+	}
+
+	if e != nil {
+		e.inputFormat, e.format, e.stderr = inputFormat, format, stderr
+		if err := e.run([]string{path}, nil, w); err != nil {
+			return err
+		}
+		// Unlike jq's exit status, an error on any document of the file
+		// leaves it untouched.
+		if inPlace && e.failed {
+			return &exitError{status: 5}
+		}
+		return nil
+	}
+
+	t := jqPipe{keepComments: true, slurp: yq.slurp, seq: yq.pipe.seq, file: path,
+		documentOptions: yq.documentOptions}
+	t.inputFormat, t.format = inputFormat, format
+	var input, output bytes.Buffer
+	if err := t.toJSON(reader, &input); err != nil {
+		return inFile(err, path)
+	}
+
+	cmd := exec.Command(yq.jqCmd.Path, yq.jqCmd.Args[1:]...)
+	cmd.Stdin = &input
+	cmd.Stdout = &output
+	cmd.Stderr = stderr
+	if !yq.returnYAML {
+		cmd.Stdout = w
+	}
+	err = jqExitError(cmd.Run())
+	if !yq.returnYAML || err != nil && inPlace {
+		return err
+	}
+
+	if err := t.toYAML(&output, w); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return err
+}
+
+// writeFileAtomic replaces the contents of path with data by renaming a
+// temporary file over it, keeping the original file mode.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// report writes err to w, unless it was already reported, and returns the
+// status yq exits with because of it.
+func (yq *yq) report(w io.Writer, err error) int {
+	status := 1
+	if e, ok := err.(*exitError); ok {
+		status = e.status
+		if e.message == "" {
+			return status
+		}
+	}
+	reportError(w, err, yq.errorFormat)
+	return status
+}
+
+// Main runs the yq command with args, the first of which is the name it
+// runs as, and returns the status it exits with. version is the version
+// --version reports.
+func Main(args []string, version string) int {
+	var y yq
+
+	if err := y.compileJqCmd(args, os.Stderr); err != nil {
+		name := filepath.Base(args[0])
+		switch err {
+		case errHelp:
+			y.usage(os.Stdout, name)
+			return 0
+		case errVersion:
+			fmt.Printf("%s %s\n", name, version)
+			return 0
+		}
+		return y.report(os.Stderr, err)
+	}
+
+	if err := y.run(); err != nil {
+		return y.report(os.Stderr, err)
+	}
+	return 0
+}
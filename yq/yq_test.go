@@ -0,0 +1,1088 @@
+package yq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type buffer struct {
+	bytes.Buffer
+}
+
+func (b *buffer) Close() error {
+	return nil
+}
+
+// startedBuffer is a buffer safe for concurrent use that closes started
+// when it is first written to.
+type startedBuffer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	started chan struct{}
+}
+
+func (b *startedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf.Len() == 0 && len(p) > 0 {
+		close(b.started)
+	}
+	return b.buf.Write(p)
+}
+
+func (b *startedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTransformToJSON(t *testing.T) {
+
+	type testCase struct {
+		testDescription string
+		yaml            string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Valid YAML single document",
+			`foo: bar`,
+			`{"foo":"bar"}`,
+			false,
+		},
+		{
+			"No HTML escaping",
+			`foo: <bar>`,
+			`{"foo":"<bar>"}`,
+			false,
+		},
+		{
+			"Valid YAML multiple documents",
+			`---
+foo: bar
+---
+bar: baz`,
+			`{"foo":"bar"}
+{"bar":"baz"}`,
+			false,
+		},
+		{
+			"Valid YAML multiple empty documents",
+			`---
+foo: bar
+---
+---
+bar: baz
+---`,
+			`{"foo":"bar"}
+{"bar":"baz"}`,
+			false,
+		},
+		{
+			"Invalid YAML tabs for indentation",
+			`---
+foo: bar
+	baz: boo
+`,
+			"",
+			true,
+		},
+		{
+			"Invalid YAML duplicate keys",
+			`---
+foo: bar
+foo: boo
+`,
+			"",
+			true,
+		},
+		{
+			"Invalid YAML mixed types",
+			`---
+foo: bar
+baz:
+  - boo
+  bob: alice
+`,
+			"",
+			true,
+		},
+		{
+			"Preserves mapping key order",
+			`zulu: 1
+alpha:
+  yankee: true
+  bravo: [2, "x"]`,
+			`{"zulu":1,"alpha":{"yankee":true,"bravo":[2,"x"]}}`,
+			false,
+		},
+		{
+			"Expands merge keys with explicit keys taking precedence",
+			`base: &base {a: 1, b: 2}
+child:
+  b: 3
+  <<: *base
+  c: 4`,
+			`{"base":{"a":1,"b":2},"child":{"b":3,"a":1,"c":4}}`,
+			false,
+		},
+		{
+			"Invalid YAML non-string keys",
+			`1: foo`,
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b buffer
+			err := transformToJSON(
+				bytes.NewReader([]byte(tCase.yaml)),
+				&b,
+			)
+
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if !tCase.shouldError && err != nil {
+				t.Errorf("Got: %s, running transformToJSON", err)
+			}
+
+			if !tCase.shouldError && !reflect.DeepEqual(tCase.expected, actual) {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+
+			if tCase.shouldError && err == nil {
+				t.Error("Expected transformToJSON to return an error and it did not")
+			}
+		})
+	}
+}
+
+func TestTransformToYAML(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		json            string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Single JSON object",
+			`{"foo": "bar"}`,
+			`foo: bar`,
+		},
+		{
+			"Array of JSON objects",
+			`[
+			   {"foo": "bar"},
+			   {"bar": "baz"}
+			 ]`,
+			`- foo: bar
+- bar: baz`,
+		},
+		{
+			"Multiple JSON objects",
+			` {"foo": "bar"}
+			 {"bar": "baz"}`,
+			`foo: bar
+---
+bar: baz`,
+		},
+		{
+			"Preserves JSON object key order",
+			`{"zulu": 1, "alpha": {"yankee": "true", "bravo": null}}`,
+			`zulu: 1
+alpha:
+  yankee: "true"
+  bravo: null`,
+		},
+	}
+	for _, tCase := range testcases {
+		var arr []string
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var b bytes.Buffer
+			err := transformToYAML(
+				bytes.NewReader([]byte(tCase.json)),
+				&b,
+			)
+
+			actual := strings.Trim(b.String(), "\r\n")
+
+			if err != nil {
+				t.Errorf("Got: %s, running transformToJSON", err)
+			}
+
+			if !reflect.DeepEqual(tCase.expected, actual) {
+				t.Errorf("Expected %v got %v", tCase.expected, arr)
+			}
+		})
+	}
+}
+
+func TestCompileCommand(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expectedCmd     []string
+		expectedFiles   []string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Works when there is no file passed",
+			[]string{"yq", "-r", "."},
+			[]string{"jq", "-r", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Errors when there is no flag passed",
+			[]string{"yq"},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors when there is help flag passed",
+			[]string{"yq", "-h"},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Works with single arg",
+			[]string{"yq", "-r", ".", "test_resources/foo.yaml"},
+			[]string{"jq", "-r", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with multiple args",
+			[]string{"yq", "-r", "-s", ".", "test_resources/foo.yaml"},
+			[]string{"jq", "-r", "-s", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with jq slurpfile flag",
+			[]string{"yq", "--slurpfile", "a", "b", ".", "test_resources/foo.yaml"},
+			[]string{"jq", "--slurpfile", "a", "b", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with jq rawfile flag",
+			[]string{"yq", "--rawfile", "a", "b", ".", "test_resources/foo.yaml"},
+			[]string{"jq", "--rawfile", "a", "b", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with clustered short flags",
+			[]string{"yq", "-sc", ".", "test_resources/foo.yaml"},
+			[]string{"jq", "-c", "-s", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with clustered raw and join flags",
+			[]string{"yq", "-rj", "."},
+			[]string{"jq", "-r", "-j", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Works with flags after the filter",
+			[]string{"yq", ".", "-c", "test_resources/foo.yaml", "-S"},
+			[]string{"jq", "-c", "-S", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with -- terminating flags",
+			[]string{"yq", "-c", "--", "-1"},
+			[]string{"jq", "-c", "-1"},
+			[]string{},
+			false,
+		},
+		{
+			"Works with -- after the filter",
+			[]string{"yq", ".", "--", "test_resources/foo.yaml"},
+			[]string{"jq", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with long flags",
+			[]string{"yq", "--compact-output", "--null-input", "--exit-status",
+				"--raw-output", "--join-output", "--ascii-output", "--slurp",
+				"--raw-input", "--color-output", "--monochrome-output",
+				"--sort-keys", "--yaml-output", "."},
+			[]string{"jq", "-c", "-n", "-e", "-r", "-j", "-a", "-s", "-R", "-M",
+				"-S", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Works with short flags",
+			[]string{"yq", "-c", "-n", "-e", "-r", "-j", "-a", "-s", "-R", "-C",
+				"-M", "-S", "-y", "."},
+			[]string{"jq", "-c", "-n", "-e", "-r", "-j", "-a", "-s", "-R", "-M",
+				"-S", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Forwards -C when jq's output is not transcoded to YAML",
+			[]string{"yq", "-C", "."},
+			[]string{"jq", "-C", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Works with output formatting flags",
+			[]string{"yq", "--tab", "--seq", "--stream", "--unbuffered", "."},
+			[]string{"jq", "--tab", "--seq", "--stream", "--unbuffered", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Does not forward --seq when jq's output is transcoded to YAML",
+			[]string{"yq", "-y", "--seq", "."},
+			[]string{"jq", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Works with jq indent flag",
+			[]string{"yq", "--tab", "--indent", "4", "."},
+			[]string{"jq", "--indent", "4", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Errors when indenting more than 7 characters",
+			[]string{"yq", "--indent", "8", "."},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Works with jq library path flag",
+			[]string{"yq", "-L", "lib", "-Lmodules", "."},
+			[]string{"jq", "-L", "lib", "-L", "modules", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Works with jq from-file flag",
+			[]string{"yq", "-f", "test_resources/foo.jq", "test_resources/foo.yaml"},
+			[]string{"jq", "-f", "test_resources/foo.jq"},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with jq from-file flag clustered",
+			[]string{"yq", "-sf", "test_resources/foo.jq"},
+			[]string{"jq", "-s", "-f", "test_resources/foo.jq"},
+			[]string{},
+			false,
+		},
+		{
+			"Works with jq long from-file flag",
+			[]string{"yq", "--from-file", "test_resources/foo.jq"},
+			[]string{"jq", "-f", "test_resources/foo.jq"},
+			[]string{},
+			false,
+		},
+		{
+			"Works with jq arg flag after the filter",
+			[]string{"yq", ".", "--arg", "a", "b", "test_resources/foo.yaml"},
+			[]string{"jq", "--arg", "a", "b", "."},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with repeated jq variable flags",
+			[]string{"yq", "--arg", "a", "1", "--arg", "b", "2", "--argjson",
+				"c", `{"d": 3}`, "--slurpfile", "e", "f", "--rawfile", "g", "h",
+				"--argfile", "i", "j", "--arg", "a", "3", "."},
+			[]string{"jq", "--arg", "a", "1", "--arg", "b", "2", "--argjson",
+				"c", `{"d": 3}`, "--slurpfile", "e", "f", "--rawfile", "g", "h",
+				"--argfile", "i", "j", "--arg", "a", "3", "."},
+			[]string{},
+			false,
+		},
+		{
+			"Errors when jq argjson flag is not valid JSON",
+			[]string{"yq", "--argjson", "a", "{", "."},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors when jq argjson flag holds several JSON texts",
+			[]string{"yq", "--argjson", "a", "1 2", "."},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Works with jq args flag",
+			[]string{"yq", "-n", "$ARGS", "--args", "a", "test_resources/foo.yaml"},
+			[]string{"jq", "-n", "$ARGS", "--args", "a", "test_resources/foo.yaml"},
+			[]string{},
+			false,
+		},
+		{
+			"Works with jq args flag before the filter",
+			[]string{"yq", "--args", ".", "a", "b"},
+			[]string{"jq", ".", "--args", "a", "b"},
+			[]string{},
+			false,
+		},
+		{
+			"Works with files before jq args flag",
+			[]string{"yq", ".", "test_resources/foo.yaml", "--args", "a"},
+			[]string{"jq", ".", "--args", "a"},
+			[]string{"test_resources/foo.yaml"},
+			false,
+		},
+		{
+			"Works with jq jsonargs flag",
+			[]string{"yq", "-n", "$ARGS", "--jsonargs", "1", `{"a": 2}`,
+				"--args", "b"},
+			[]string{"jq", "-n", "$ARGS", "--jsonargs", "1", `{"a": 2}`,
+				"--args", "b"},
+			[]string{},
+			false,
+		},
+		{
+			"Errors when jq jsonargs flag is followed by invalid JSON",
+			[]string{"yq", "-n", "$ARGS", "--jsonargs", "{"},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors when jq arg flag misses parameters",
+			[]string{"yq", ".", "--arg", "a"},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors on unknown short flag",
+			[]string{"yq", "-Z", "."},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors on unknown flag in a cluster",
+			[]string{"yq", "-sZ", "."},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors on unknown long flag",
+			[]string{"yq", "--bogus", "."},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Errors when there is no filter",
+			[]string{"yq", "-c"},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"Complex jq args",
+			[]string{"yq", "-y", "-s", ".[0] * .[1]", "test_resources/foo.yaml", "test_resources/foo.yaml"},
+			[]string{"jq", "-s", ".[0] * .[1]"},
+			[]string{"test_resources/foo.yaml", "test_resources/foo.yaml"},
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		var y yq
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			y.jqCmd.Path = "jq"
+			err := y.compileJqCmd(tCase.osArgs, ioutil.Discard)
+			actualCmd := y.jqCmd.Args
+			actualFiles := y.files
+
+			if !tCase.shouldError && err != nil {
+				t.Error("Did not expect an error got: ", err)
+			}
+
+			if !tCase.shouldError && !reflect.DeepEqual(tCase.expectedCmd, actualCmd) {
+				t.Errorf("Expected: '%v', got: '%v'", tCase.expectedCmd, actualCmd)
+			}
+
+			if !tCase.shouldError &&
+				(!(len(tCase.expectedFiles) == 0 && len(actualFiles) == 0) &&
+					!reflect.DeepEqual(tCase.expectedFiles, actualFiles)) {
+				t.Errorf("Expected: '%v', got: '%v'", tCase.expectedFiles, actualFiles)
+			}
+		})
+	}
+}
+
+func TestJQPipePreservesComments(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		yaml            string
+		jqOutput        string
+		slurp           bool
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Keeps head, line and foot comments of unchanged nodes",
+			`# head
+foo: bar # line
+list:
+  - a # first
+  # foot`,
+			`{"foo":"bar","list":["a"]}`,
+			false,
+			`# head
+foo: bar # line
+list:
+  - a # first
+  # foot`,
+		},
+		{
+			"Keeps comments of modified scalars",
+			`replicas: 1 # count`,
+			`{"replicas":3}`,
+			false,
+			`replicas: 3 # count`,
+		},
+		{
+			"Drops comments of deleted nodes",
+			`keep: 1 # kept
+# dropped
+gone: 2
+replaced: # replaced
+  a: 1`,
+			`{"keep":1,"replaced":"now a string"}`,
+			false,
+			`keep: 1 # kept
+replaced: now a string # replaced`,
+		},
+		{
+			"Maps comments by document index",
+			`a: 1 # first
+---
+b: 2 # second`,
+			`{"a":1}
+{"b":3}`,
+			false,
+			`a: 1 # first
+---
+b: 3 # second`,
+		},
+		{
+			"Drops comments when jq emits fewer documents, as with select",
+			`a: 1 # first
+---
+a: 2 # second`,
+			`{"a":2}`,
+			false,
+			`a: 2`,
+		},
+		{
+			"Drops comments when jq emits more documents",
+			`a: [1, 2] # list`,
+			`{"a":1}
+{"a":2}`,
+			false,
+			`a: 1
+---
+a: 2`,
+		},
+		{
+			"Maps comments into slurped documents",
+			`a: 1 # first
+---
+b: 2 # second`,
+			`[{"a":1},{"b":2}]`,
+			true,
+			`- a: 1 # first
+- b: 2 # second`,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			tr := jqPipe{keepComments: true, slurp: tCase.slurp}
+			var b bytes.Buffer
+			if err := tr.toJSON(strings.NewReader(tCase.yaml), ioutil.Discard); err != nil {
+				t.Fatalf("Got: %s, running toJSON", err)
+			}
+			if err := tr.toYAML(strings.NewReader(tCase.jqOutput), &b); err != nil {
+				t.Fatalf("Got: %s, running toYAML", err)
+			}
+
+			actual := strings.Trim(b.String(), "\r\n")
+			if tCase.expected != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestJQPipeRoundTrip(t *testing.T) {
+	type testCase struct {
+		testDescription string
+		options         documentOptions
+		yaml            string
+		json            string
+		jqOutput        string
+		expected        string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Preserves tags, anchors and aliases",
+			documentOptions{properties: nodeProperties{tags: true, anchors: true}},
+			"a: &x !T\n  b: !Ref B # comment\nc: *x\nd: !!binary aGVsbG8=\n",
+			`{"a":{"$tag":"!T","$anchor":"x","$value":{"b":{"$tag":"!Ref",` +
+				`"$value":"B"}}},"c":{"$alias":"x"},` +
+				`"d":{"$tag":"!!binary","$value":"aGVsbG8="}}`,
+			"",
+			"a: &x !T\n  b: !Ref B # comment\nc: *x\nd: !!binary aGVsbG8=\n",
+			false,
+		},
+		{
+			"Preserves merge keys and their aliases",
+			documentOptions{mergeKeys: "preserve",
+				properties: nodeProperties{anchors: true}},
+			"x: &x\n  a: 1\njob:\n  <<: *x\n  b: 2\n",
+			`{"x":{"$anchor":"x","$value":{"a":1}},"job":{"<<":{"$alias":"x"},"b":2}}`,
+			"",
+			"x: &x\n  a: 1\njob:\n  <<: *x\n  b: 2\n",
+			false,
+		},
+		{
+			"Wraps documents with their file name and index",
+			documentOptions{withFilename: true},
+			"# head\na: 1 # comment\n---\nb: 2\n",
+			`{"file":"a.yaml","doc":1,"value":{"a":1}}` + "\n" +
+				`{"file":"a.yaml","doc":2,"value":{"b":2}}`,
+			"",
+			"# head\na: 1 # comment\n---\nb: 2\n",
+			false,
+		},
+		{
+			"Writes integer keys back as integers",
+			documentOptions{keyPolicy: "stringify", restoreIntKeys: true},
+			"1: a # one\n\"true\": b\nc:\n  -2: d\n  \"03\": e\n",
+			`{"1":"a","true":"b","c":{"-2":"d","03":"e"}}`,
+			"",
+			"1: a # one\n\"true\": b\nc:\n  -2: d\n  \"03\": e\n",
+			false,
+		},
+		{
+			"Keeps the literals of numbers only when their value is the same",
+			documentOptions{},
+			"a: 0x1F\nb: [1.0, 12345678901234567890]\n",
+			`{"a":31,"b":[1,12345678901234567890]}`,
+			`{"a":31,"b":[1,12345678901234567891]}`,
+			"a: 0x1F\nb:\n  - 1.0\n  - 12345678901234567891\n",
+			false,
+		},
+		{
+			"Maps special floats, timestamps and binary values both ways",
+			documentOptions{scalars: scalarPolicy{specialFloats: "string",
+				timestamps: "epoch"}},
+			"a: [.inf, 2001-12-14]\nb: !!binary aGVsbG8=\n",
+			`{"a":[".inf",1008288000],"b":"hello"}`,
+			"",
+			"a:\n  - .inf\n  - 2001-12-14\nb: !!binary aGVsbG8=\n",
+			false,
+		},
+		{
+			"Keeps the base64 text of binary values whose bytes are not UTF-8",
+			documentOptions{},
+			"bin: !!binary /9j/4AAQ\nother: 1\n",
+			`{"bin":"/9j/4AAQ","other":1}`,
+			`{"bin":"/9j/4AAQ","other":2}`,
+			"bin: !!binary /9j/4AAQ\nother: 2\n",
+			false,
+		},
+		{
+			"Keeps the comments of a value moved by the filter, as with .a",
+			documentOptions{},
+			"b: 1 # root b\na:\n  b: 2 # a b\n",
+			`{"b":1,"a":{"b":2}}`,
+			`{"b":2}`,
+			"b: 2 # a b\n",
+			false,
+		},
+		{
+			"Keeps the comments of the items select keeps",
+			documentOptions{},
+			"# one\n- x: 1 # x one\n# two\n- x: 2 # x two\n",
+			`[{"x":1},{"x":2}]`,
+			`[{"x":2}]`,
+			"# two\n- x: 2 # x two\n",
+			false,
+		},
+		{
+			"Keeps the comments of the items reverse moves",
+			documentOptions{},
+			"- 1 # one\n- 0x2 # two\n",
+			`[1,2]`,
+			`[2,1]`,
+			"- 0x2 # two\n- 1 # one\n",
+			false,
+		},
+		{
+			"Errors on aliases whose anchor the filter removed",
+			documentOptions{properties: nodeProperties{anchors: true}},
+			"base: &b\n  x: 1\ncopy: *b\n",
+			`{"base":{"$anchor":"b","$value":{"x":1}},"copy":{"$alias":"b"}}`,
+			`{"copy":{"$alias":"b"}}`,
+			"",
+			true,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			tr := jqPipe{keepComments: true, file: "a.yaml",
+				documentOptions: tCase.options}
+			var jqInput, result bytes.Buffer
+			if err := tr.toJSON(strings.NewReader(tCase.yaml), &jqInput); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			if actual := strings.Trim(jqInput.String(), "\r\n"); tCase.json != actual {
+				t.Errorf("Expected '%v' got '%v'", tCase.json, actual)
+			}
+
+			jqOutput := tCase.jqOutput
+			if jqOutput == "" {
+				jqOutput = jqInput.String()
+			}
+			err := tr.toYAML(strings.NewReader(jqOutput), &result)
+			if !tCase.shouldError && err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			if tCase.shouldError && err == nil {
+				t.Error("Expected toYAML to return an error and it did not")
+			}
+			if !tCase.shouldError && tCase.expected != result.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, result.String())
+			}
+		})
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	engines := map[string][]string{"embedded jq": nil}
+	if _, err := exec.LookPath("jq"); err == nil {
+		engines["jq binary"] = []string{"--jq-binary", "jq"}
+	}
+
+	type testCase struct {
+		testDescription string
+		filter          string
+		files           map[string]string
+		expected        map[string]string
+		shouldError     bool
+	}
+	testcases := []testCase{
+		{
+			"Rewrites every file with its own result",
+			".replicas += 1",
+			map[string]string{
+				"a.yaml": "replicas: 1 # count\nname: a\n",
+				"b.yaml": "name: b\nreplicas: 5\n",
+			},
+			map[string]string{
+				"a.yaml": "replicas: 2 # count\nname: a\n",
+				"b.yaml": "name: b\nreplicas: 6\n",
+			},
+			false,
+		},
+		{
+			"Leaves every file untouched when jq fails",
+			".replicas + 1",
+			map[string]string{
+				"a.yaml": "replicas: 1\n",
+				"b.yaml": "replicas: a string\n",
+			},
+			map[string]string{
+				"a.yaml": "replicas: 1\n",
+				"b.yaml": "replicas: a string\n",
+			},
+			true,
+		},
+		{
+			"Writes TOML files back as TOML",
+			".replicas += 1",
+			map[string]string{
+				"a.yaml": "replicas: 1\n",
+				"b.toml": "replicas = 5\n\n[owner]\nname = \"b\"\n",
+			},
+			map[string]string{
+				"a.yaml": "replicas: 2\n",
+				"b.toml": "replicas = 6\n\n[owner]\nname = \"b\"\n",
+			},
+			false,
+		},
+		{
+			"Writes JSON files back as JSON",
+			".replicas += 1",
+			map[string]string{
+				"a.json": `{"replicas": 1, "name": "a"}`,
+				"b.conf": `{"replicas": 5}`,
+			},
+			map[string]string{
+				"a.json": "{\n  \"replicas\": 2,\n  \"name\": \"a\"\n}\n",
+				"b.conf": "{\n  \"replicas\": 6\n}\n",
+			},
+			false,
+		},
+	}
+	for _, tCase := range testcases {
+		names := make([]string, 0, len(tCase.files))
+		for name := range tCase.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for engine, engineArgs := range engines {
+			t.Run(tCase.testDescription+" with "+engine, func(t *testing.T) {
+				dir, err := ioutil.TempDir("", "yq")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer os.RemoveAll(dir)
+
+				osArgs := append([]string{"yq", "-i"}, engineArgs...)
+				osArgs = append(osArgs, tCase.filter)
+				for _, name := range names {
+					path := filepath.Join(dir, name)
+					if err := ioutil.WriteFile(path, []byte(tCase.files[name]), 0640); err != nil {
+						t.Fatal(err)
+					}
+					osArgs = append(osArgs, path)
+				}
+
+				var y yq
+				if err := y.compileJqCmd(osArgs, ioutil.Discard); err != nil {
+					t.Fatal("Did not expect an error got: ", err)
+				}
+				err = y.run()
+
+				if !tCase.shouldError && err != nil {
+					t.Error("Did not expect an error got: ", err)
+				}
+				if tCase.shouldError && err == nil {
+					t.Error("Expected run to return an error and it did not")
+				}
+
+				for name, expected := range tCase.expected {
+					path := filepath.Join(dir, name)
+					actual, err := ioutil.ReadFile(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if expected != string(actual) {
+						t.Errorf("Expected %s to be '%v' got '%v'", name, expected, string(actual))
+					}
+					info, err := os.Stat(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if info.Mode() != 0640 {
+						t.Errorf("Expected %s to keep mode 0640 got %v", name, info.Mode())
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRunJqComments(t *testing.T) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		t.Skip("jq is not installed")
+	}
+
+	input := "# first doc\na: 1 # one\n---\n# second doc\na: 2 # two\n"
+	type testCase struct {
+		testDescription string
+		filter          string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Keeps the comments of each document",
+			".a += 10",
+			"# first doc\na: 11 # one\n---\n# second doc\na: 12 # two\n",
+		},
+		{
+			"Does not move comments onto another document",
+			"select(.a == 2)",
+			"a: 2\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			osArgs := []string{"yq", "-y", "--jq-binary", "jq", tCase.filter}
+			if err := y.compileJqCmd(osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var out bytes.Buffer
+			if err := y.runJq(strings.NewReader(input), &out); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			if tCase.expected != out.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestRunJqSeq(t *testing.T) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		t.Skip("jq is not installed")
+	}
+
+	input := "a: 1\n---\na: 2\n"
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Feeds jq the record separators it reads with --seq",
+			[]string{"yq", "-c", "--jq-binary", "jq", "--seq", "."},
+			"\x1e{\"a\":1}\n\x1e{\"a\":2}\n",
+		},
+		{
+			"Feeds raw lines without record separators",
+			[]string{"yq", "-R", "--jq-binary", "jq", "--seq", "."},
+			"\x1e\"{\\\"a\\\":1}\"\n\x1e\"{\\\"a\\\":2}\"\n",
+		},
+		{
+			"Ignores --seq for YAML output",
+			[]string{"yq", "-y", "--jq-binary", "jq", "--seq", "."},
+			input,
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			var out bytes.Buffer
+			if err := y.runJq(strings.NewReader(input), &out); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+			if tCase.expected != out.String() {
+				t.Errorf("Expected '%v' got '%v'", tCase.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestRunJqStreamsLargeInputs(t *testing.T) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		t.Skip("jq is not installed")
+	}
+
+	// Several megabytes, far more than the pipes to and from jq hold.
+	var input, expected strings.Builder
+	for i := 0; i < 20000; i++ {
+		doc := fmt.Sprintf("id: %d # document %d\nname: %s\n", i, i, strings.Repeat("x", 200))
+		input.WriteString("---\n" + doc)
+		if i > 0 {
+			expected.WriteString("---\n")
+		}
+		expected.WriteString(doc)
+	}
+
+	type testCase struct {
+		testDescription string
+		osArgs          []string
+		expected        string
+	}
+	testcases := []testCase{
+		{
+			"Transcodes jq's output while feeding it",
+			[]string{"yq", "-y", "--jq-binary", "jq", "."},
+			expected.String(),
+		},
+		{
+			"Stops feeding jq when it exits early",
+			[]string{"yq", "-c", "--jq-binary", "jq", "-n", "input.id"},
+			"0\n",
+		},
+	}
+	for _, tCase := range testcases {
+		t.Run(tCase.testDescription, func(t *testing.T) {
+			var y yq
+			if err := y.compileJqCmd(tCase.osArgs, ioutil.Discard); err != nil {
+				t.Fatal("Did not expect an error got: ", err)
+			}
+
+			// The second half of the input is only written once some
+			// output was, so that yq must stream it.
+			out := &startedBuffer{started: make(chan struct{})}
+			stdin, feed := io.Pipe()
+			defer stdin.Close()
+			go func() {
+				half := input.Len() / 2
+				io.WriteString(feed, input.String()[:half])
+				select {
+				case <-out.started:
+				case <-time.After(time.Minute):
+					feed.CloseWithError(errors.New("no output before the end of the input"))
+					return
+				}
+				io.WriteString(feed, input.String()[half:])
+				feed.Close()
+			}()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- y.runJq(stdin, out)
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatal("Did not expect an error got: ", err)
+				}
+			case <-time.After(2 * time.Minute):
+				y.jqCmd.Process.Kill()
+				t.Fatal("Expected yq to finish, it is still waiting on jq")
+			}
+			if actual := out.String(); tCase.expected != actual {
+				t.Errorf("Expected %d bytes of output got %d", len(tCase.expected), len(actual))
+			}
+		})
+	}
+}